@@ -0,0 +1,92 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// writeArchive writes a gzipped tarball to path holding tree.txt, an indented listing of
+// the ownership tree under root, and logs/namespace_pod_container.log for every
+// selected container of pods, written with the format settings of lw. If some of the log streams fail the archive is still
+// written, with the logs that could be read, and the error is returned.
+func writeArchive(path string, client corev1client.PodsGetter, pods []corev1.Pod, opts logOptions, lw *logWriter, root unstructured.Unstructured, objs objectDirectory) error {
+	tmp, err := os.MkdirTemp("", "tlogs-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	logsDir := filepath.Join(tmp, "logs")
+	if err := os.Mkdir(logsDir, 0o755); err != nil {
+		return err
+	}
+	w := *lw
+	w.out, w.dir, w.files, w.color = io.Discard, logsDir, make(map[string]*os.File), false
+	streamErr := streamLogs(client, pods, opts, &w)
+	if err := w.close(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "tree.txt"), []byte(renderTree(root, objs, opts.podStates.keepObject, false)), 0o644); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.Walk(tmp, func(p string, info os.FileInfo, err error) error {
+		if err != nil || p == tmp {
+			return err
+		}
+		rel, err := filepath.Rel(tmp, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if streamErr != nil {
+		return fmt.Errorf("wrote incomplete archive %s: %w", path, streamErr)
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWriteArchiveKeepsOutputFormat(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-1"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}
+	client := fake.NewSimpleClientset(&pod).CoreV1()
+	path := filepath.Join(t.TempDir(), "tree.tar.gz")
+	root := testObject("root")
+	w := &logWriter{out: os.Stdout, format: "json", cluster: "prod", color: true}
+
+	if err := writeArchive(path, client, []corev1.Pod{pod}, logOptions{tail: -1}, w, root, newObjectDirectory(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = string(b)
+	}
+
+	if got, want := files["tree.txt"], "ConfigMap/root\n"; got != want {
+		t.Errorf("tree.txt = %q, want %q", got, want)
+	}
+	var rec logRecord
+	if err := json.Unmarshal([]byte(files["logs/shop_web-1_app.log"]), &rec); err != nil {
+		t.Fatalf("log file is not in the json format: %v", err)
+	}
+	if rec.Cluster != "prod" || rec.Pod != "web-1" || rec.Container != "app" || rec.Message != "fake logs" {
+		t.Errorf("unexpected log record %+v", rec)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// healthPolicy describes what a healthy tree looks like, loaded from a YAML file:
+//
+//	requiredKinds: [Deployment, Service]
+//	minReadyReplicas: 2
+//	maxRestarts: 3
+type healthPolicy struct {
+	// RequiredKinds must each have at least one object in the tree.
+	RequiredKinds []string `json:"requiredKinds,omitempty"`
+	// MinReadyReplicas is the lowest number of ready replicas allowed for
+	// Deployments and StatefulSets.
+	MinReadyReplicas *int64 `json:"minReadyReplicas,omitempty"`
+	// MaxRestarts is the highest restart count allowed for any container of a pod.
+	MaxRestarts *int64 `json:"maxRestarts,omitempty"`
+}
+
+// healthVerdict is the result of evaluating a tree against a healthPolicy.
+type healthVerdict struct {
+	Healthy bool
+	Reasons []string // one per violation, prefixed with the offending object
+}
+
+func loadHealthPolicy(path string) (healthPolicy, error) {
+	var p healthPolicy
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, fmt.Errorf("failed to read policy: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(b, &p); err != nil {
+		return p, fmt.Errorf("failed to parse policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// evaluateTreeHealth checks the objects in uids against policy.
+func evaluateTreeHealth(objs objectDirectory, uids map[types.UID]bool, policy healthPolicy) healthVerdict {
+	var tree []unstructured.Unstructured
+	for uid := range uids {
+		if o, ok := objs.items[uid]; ok {
+			tree = append(tree, o)
+		}
+	}
+	sort.Slice(tree, func(i, j int) bool { return displayName(tree[i]) < displayName(tree[j]) })
+
+	var reasons []string
+	for _, kind := range policy.RequiredKinds {
+		found := false
+		for _, o := range tree {
+			if strings.EqualFold(o.GetKind(), kind) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			reasons = append(reasons, fmt.Sprintf("no %s in the tree", kind))
+		}
+	}
+
+	for _, o := range tree {
+		switch {
+		case policy.MinReadyReplicas != nil && (o.GetKind() == "Deployment" || o.GetKind() == "StatefulSet"):
+			ready, _, _ := unstructured.NestedInt64(o.Object, "status", "readyReplicas")
+			if ready < *policy.MinReadyReplicas {
+				reasons = append(reasons, fmt.Sprintf("%s: %d ready replicas, want at least %d",
+					displayName(o), ready, *policy.MinReadyReplicas))
+			}
+		case policy.MaxRestarts != nil && o.GetKind() == "Pod":
+			statuses, _, _ := unstructured.NestedSlice(o.Object, "status", "containerStatuses")
+			for _, s := range statuses {
+				cs, ok := s.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _, _ := unstructured.NestedString(cs, "name")
+				restarts, _, _ := unstructured.NestedInt64(cs, "restartCount")
+				if restarts > *policy.MaxRestarts {
+					reasons = append(reasons, fmt.Sprintf("%s: container %s restarted %d times, want at most %d",
+						displayName(o), name, restarts, *policy.MaxRestarts))
+				}
+			}
+		}
+	}
+	return healthVerdict{Healthy: len(reasons) == 0, Reasons: reasons}
+}
+
+// printHealthCheck prints the verdict of the tree against the policy at path and
+// returns an error if the tree is unhealthy, for use as a pipeline gate.
+func printHealthCheck(path string, objs objectDirectory, uids map[types.UID]bool) error {
+	policy, err := loadHealthPolicy(path)
+	if err != nil {
+		return err
+	}
+	v := evaluateTreeHealth(objs, uids, policy)
+	if v.Healthy {
+		fmt.Println("healthy")
+		return nil
+	}
+	for _, r := range v.Reasons {
+		fmt.Println(r)
+	}
+	return fmt.Errorf("tree is unhealthy: %d violation(s)", len(v.Reasons))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestEvaluateTreeHealth(t *testing.T) {
+	deployment := testObject("web")
+	deployment.SetAPIVersion("apps/v1")
+	deployment.SetKind("Deployment")
+	_ = unstructured.SetNestedField(deployment.Object, int64(1), "status", "readyReplicas")
+
+	pod := testObject("web-1", "web")
+	pod.SetKind("Pod")
+	_ = unstructured.SetNestedSlice(pod.Object, []interface{}{
+		map[string]interface{}{"name": "app", "restartCount": int64(5)},
+		map[string]interface{}{"name": "sidecar", "restartCount": int64(0)},
+	}, "status", "containerStatuses")
+
+	objs := newObjectDirectory([]unstructured.Unstructured{deployment, pod})
+	uids := map[types.UID]bool{"web": true, "web-1": true}
+	int64p := func(i int64) *int64 { return &i }
+
+	tests := []struct {
+		desc   string
+		policy healthPolicy
+		want   []string
+	}{
+		{"empty policy", healthPolicy{}, nil},
+		{"required kinds present", healthPolicy{RequiredKinds: []string{"deployment", "Pod"}}, nil},
+		{"required kind missing", healthPolicy{RequiredKinds: []string{"Service"}}, []string{"no Service in the tree"}},
+		{"enough ready replicas", healthPolicy{MinReadyReplicas: int64p(1)}, nil},
+		{"too few ready replicas", healthPolicy{MinReadyReplicas: int64p(2)},
+			[]string{"Deployment/web: 1 ready replicas, want at least 2"}},
+		{"restarts within limit", healthPolicy{MaxRestarts: int64p(5)}, nil},
+		{"too many restarts", healthPolicy{MaxRestarts: int64p(3)},
+			[]string{"Pod/web-1: container app restarted 5 times, want at most 3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			v := evaluateTreeHealth(objs, uids, tt.policy)
+			if v.Healthy != (len(tt.want) == 0) || !reflect.DeepEqual(v.Reasons, tt.want) {
+				t.Errorf("evaluateTreeHealth() = %+v, want reasons %q", v, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/homedir"
+)
+
+// commands are the subcommands of tlogs. Without one, tlogs streams the logs of the tree.
+var commands = []struct {
+	name string
+	help string
+	run  func(args []string) error
+}{
+	{"tree", "print the ownership tree", runTree},
+	{"events", "stream Events of objects in the tree", runEvents},
+	{"quota", "show the namespace's ResourceQuota usage attributable to the tree and the LimitRange constraints that apply to it", runQuota},
+	{"terminations", "show container termination history (exit codes, OOMKilled) of pods in the tree", runTerminations},
+	{"terminating", "show objects in the tree stuck in deletion and their remaining finalizers", runTerminating},
+	{"check", "evaluate the tree against a YAML health policy and exit non-zero if it is violated", runCheck},
+	{"simulate-delete", "show what deleting the root with a propagation policy would remove, without deleting anything", runSimulateDelete},
+	{"metrics-link", "print a link for every pod of the tree from a Go template", runMetricsLink},
+	{"plan", "print the API resources that would be listed and an estimated request count", runPlan},
+	{"evict", "evict a pod of the tree, respecting PodDisruptionBudgets", runEvict},
+	{"drain-node", "evict the tree's pods running on a node", runDrainNode},
+	{"scale", "scale the tree's top-most scalable objects", runScale},
+	{"pause", "pause reconciliation of the tree's Deployments, CronJobs, Flux resources and Argo CD Applications", runPause},
+	{"resume", "undo pause", runResume},
+	{"cp", "copy files between a pod of the tree and the local filesystem", runCopy},
+	{"probe", "check DNS and connectivity from a pod of the tree to a target", runProbe},
+}
+
+// treeFlags are the flags of every command to connect to the cluster and load the tree.
+type treeFlags struct {
+	kubeconfig string
+	namespace  string
+	noCache    bool
+	maxObjects int64
+	readOnly   bool
+}
+
+func addTreeFlags(fs *flag.FlagSet) *treeFlags {
+	f := &treeFlags{}
+	if home := homedir.HomeDir(); home != "" {
+		fs.StringVar(&f.kubeconfig, "kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
+	} else {
+		fs.StringVar(&f.kubeconfig, "kubeconfig", "", "absolute path to the kubeconfig file")
+	}
+	fs.StringVar(&f.namespace, "namespace", "", "namespace of the root object (defaults to the kubeconfig context's namespace)")
+	fs.StringVar(&f.namespace, "n", "", "shorthand for --namespace")
+	fs.BoolVar(&f.noCache, "no-cache", false, "ignore the cached API discovery results and fetch them from the server")
+	fs.Int64Var(&f.maxObjects, "max-objects", 0, "abort if the namespace holds more than this many objects to list (0 means no limit)")
+	fs.BoolVar(&f.readOnly, "read-only", false, "refuse to change the cluster: evict, drain-node, scale, pause, resume, cp into a pod and probe with --yes fail")
+	return f
+}
+
+// refuse returns an error if --read-only is set, for a command that changes the cluster.
+func (f *treeFlags) refuse(what string) error {
+	if f.readOnly {
+		return fmt.Errorf("%s changes the cluster and cannot be used with --read-only", what)
+	}
+	return nil
+}
+
+// parseCommand parses the flags and positional arguments of a command, which are
+// KIND NAME followed by one argument for each of extra. Flags may also follow them.
+func parseCommand(fs *flag.FlagSet, args []string, extra ...string) (kind, name string, rest []string, err error) {
+	usage := strings.Join(append([]string{"tlogs", fs.Name(), "[flags] KIND NAME"}, extra...), " ")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 2+len(extra) {
+		return "", "", nil, fmt.Errorf("usage: %s", usage)
+	}
+	return pos[0], pos[1], pos[2:], nil
+}
+
+// parseArgs parses the command line and returns the positional arguments.
+// Unlike fs.Parse it also accepts flags after positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	fs.Parse(args)
+	var out []string
+	for args := fs.Args(); len(args) > 0; args = fs.Args() {
+		out = append(out, args[0])
+		fs.Parse(args[1:])
+	}
+	return out
+}
+
+// cluster holds the clients and API resources of the cluster a tree is loaded from.
+type cluster struct {
+	clientConfig clientcmd.ClientConfig
+	config       *rest.Config
+	dyn          dynamic.Interface
+	dc           discovery.CachedDiscoveryInterface
+	apis         *resourceMap
+	ns           string
+}
+
+func (f *treeFlags) connect() (*cluster, error) {
+	c := &cluster{ns: f.namespace}
+	c.clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: f.kubeconfig}, &clientcmd.ConfigOverrides{})
+	config, err := c.clientConfig.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.QPS = 1000
+	config.Burst = 1000
+	if f.readOnly {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper { return readOnlyTransport{rt} })
+	}
+	c.config = config
+	if c.ns == "" {
+		if c.ns, _, err = c.clientConfig.Namespace(); err != nil {
+			return nil, fmt.Errorf("failed to determine namespace: %w", err)
+		}
+	}
+	if c.dyn, err = dynamic.NewForConfig(config); err != nil {
+		return nil, err
+	}
+	if c.dc, err = newDiscoveryClient(config, !f.noCache); err != nil {
+		return nil, err
+	}
+	if c.apis, err = findAPIs(c.dc); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readOnlyTransport rejects every request that could change the cluster. Only reads
+// (get, list and watch) and exec into pods, which cp and probe run their commands
+// with, are let through; commands that write through exec are refused before.
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+func (t readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		if !isPodExec(req.URL.Path) {
+			return nil, fmt.Errorf("refusing POST %s with --read-only", req.URL.Path)
+		}
+	default:
+		return nil, fmt.Errorf("refusing %s %s with --read-only", req.Method, req.URL.Path)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// isPodExec reports whether path is the exec subresource of a pod,
+// .../namespaces/NS/pods/NAME/exec.
+func isPodExec(path string) bool {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	n := len(parts)
+	return n >= 5 && parts[n-1] == "exec" && parts[n-3] == "pods" && parts[n-5] == "namespaces"
+}
+
+// findKind returns the API resource of kind, refreshing cached discovery results if
+// it is not found in them.
+func (c *cluster) findKind(kind string) (apiResource, error) {
+	// a kind missing from a cached discovery result may have been installed since
+	if len(c.apis.lookup(kind)) == 0 && !c.dc.Fresh() {
+		c.dc.Invalidate()
+		apis, err := findAPIs(c.dc)
+		if err != nil {
+			return apiResource{}, err
+		}
+		c.apis = apis
+	}
+
+	if k, ok := overrideType(kind, c.apis); ok {
+		return k, nil
+	}
+	apiResults := c.apis.lookup(kind)
+	if len(apiResults) == 0 {
+		return apiResource{}, fmt.Errorf("could not find api kind %q", kind)
+	} else if len(apiResults) > 1 {
+		names := make([]string, 0, len(apiResults))
+		for _, a := range apiResults {
+			names = append(names, fullAPIName(a))
+		}
+		return apiResource{}, fmt.Errorf("ambiguous kind %q. use one of these as the KIND disambiguate: [%s]", kind,
+			strings.Join(names, ", "))
+	}
+	return apiResults[0], nil
+}
+
+// coreClient returns a typed client for the core API group.
+func (c *cluster) coreClient() (corev1client.CoreV1Interface, error) {
+	return corev1client.NewForConfig(c.config)
+}
+
+// objectTree is a root object and the objects of its namespace.
+type objectTree struct {
+	root unstructured.Unstructured
+	objs objectDirectory
+	uids map[types.UID]bool // the root and everything it owns
+}
+
+// loadTree fetches the root object, and all objects of its namespace to find what it owns.
+func (c *cluster) loadTree(api apiResource, name string, maxObjects int64) (*objectTree, error) {
+	obj, err := resourceInterface(c.dyn, api, c.ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s/%s: %w", api.r.Kind, name, err)
+	}
+
+	if maxObjects > 0 {
+		n, err := countAllResources(c.dyn, c.apis.resources(), c.ns)
+		if err != nil {
+			return nil, fmt.Errorf("error while counting api objects: %w", err)
+		}
+		if n > maxObjects {
+			return nil, fmt.Errorf("namespace %q has at least %d objects to list, more than --max-objects=%d", c.ns, n, maxObjects)
+		}
+	}
+
+	apiObjects, err := getAllResources(c.dyn, c.apis.resources(), c.ns)
+	if err != nil {
+		return nil, fmt.Errorf("error while querying api objects: %w", err)
+	}
+
+	t := &objectTree{root: *obj, objs: newObjectDirectory(apiObjects)}
+	t.uids = t.objs.descendants(obj.GetUID())
+	t.uids[obj.GetUID()] = true
+	return t, nil
+}
+
+// pods returns the pods of the tree.
+func (t *objectTree) pods() ([]corev1.Pod, error) {
+	return treePods(t.objs, t.uids)
+}
+
+// load connects to the cluster and loads the tree under kind and name.
+func (f *treeFlags) load(kind, name string) (*cluster, *objectTree, error) {
+	c, err := f.connect()
+	if err != nil {
+		return nil, nil, err
+	}
+	api, err := c.findKind(kind)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := c.loadTree(api, name, f.maxObjects)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, t, nil
+}
+
+// addPodStateFlags adds the flags that leave pods out of log streams and the printed
+// tree by their state.
+func addPodStateFlags(fs *flag.FlagSet) *podStateFilter {
+	f := &podStateFilter{}
+	fs.BoolVar(&f.excludeCompleted, "exclude-completed", false, "ignore Succeeded and Evicted pods")
+	fs.BoolVar(&f.onlyRunning, "only-running", false, "ignore pods that are not Running")
+	return f
+}
+
+func runTree(args []string) error {
+	fs := flag.NewFlagSet("tree", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	podStates := addPodStateFlags(fs)
+	noColor := fs.Bool("no-color", false, "do not color warnings about objects, such as expiring certificates")
+	kind, name, _, err := parseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	_, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	if len(t.objs.ownership[t.root.GetUID()]) == 0 {
+		fmt.Println("No resources are owned by this object through ownerReferences.")
+		return nil
+	}
+	fmt.Print(renderTree(t.root, t.objs, podStates.keepObject, useColor(os.Stdout, *noColor)))
+	return nil
+}
+
+func runEvents(args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	eventType := fs.String("type", "", "only show Events of this type (e.g. Warning)")
+	probeSummary := fs.Bool("probe-summary", false, "on interrupt, print how often each container's probes failed while streaming, the mean time between failures and its restarts")
+	kind, name, _, err := parseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	c, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	var probes probeFailures
+	if *probeSummary {
+		probes = make(probeFailures)
+	}
+	return streamEvents(c.dyn, newTreeIndex(c.dyn, c.apis, c.ns, t.objs, t.uids), *eventType, probes)
+}
+
+func runQuota(args []string) error {
+	fs := flag.NewFlagSet("quota", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	kind, name, _, err := parseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	c, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	return printQuota(c.dyn, c.ns, c.apis, t.objs, t.uids)
+}
+
+func runTerminations(args []string) error {
+	fs := flag.NewFlagSet("terminations", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	kind, name, _, err := parseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	_, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	return printTerminations(t.objs, t.uids)
+}
+
+func runTerminating(args []string) error {
+	fs := flag.NewFlagSet("terminating", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	kind, name, _, err := parseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	_, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	return printTerminating(t.objs, t.uids)
+}
+
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	kind, name, rest, err := parseCommand(fs, args, "POLICY")
+	if err != nil {
+		return err
+	}
+	_, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	return printHealthCheck(rest[0], t.objs, t.uids)
+}
+
+func runSimulateDelete(args []string) error {
+	fs := flag.NewFlagSet("simulate-delete", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	target := fs.String("target", "", "simulate deleting this object of the tree, given as Kind/name, instead of the root")
+	kind, name, rest, err := parseCommand(fs, args, "background|foreground|orphan")
+	if err != nil {
+		return err
+	}
+	propagation, ok := deletionPolicies[strings.ToLower(rest[0])]
+	if !ok {
+		return fmt.Errorf("unknown propagation policy %q, use one of: background, foreground, orphan", rest[0])
+	}
+	_, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	root := t.root
+	if *target != "" {
+		if root, err = findTreeObject(t.objs, t.uids, *target); err != nil {
+			return err
+		}
+	}
+	return printDeleteSimulation(root, t.objs, propagation)
+}
+
+func runMetricsLink(args []string) error {
+	fs := flag.NewFlagSet("metrics-link", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	kind, name, rest, err := parseCommand(fs, args, "TEMPLATE")
+	if err != nil {
+		return err
+	}
+	// e.g. 'https://prometheus.example.com/graph?g0.expr={{urlquery .Selector}}'
+	tmpl, err := template.New("metrics-link").Option("missingkey=zero").Parse(rest[0])
+	if err != nil {
+		return fmt.Errorf("invalid metrics link template: %w", err)
+	}
+	_, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	pods, err := t.pods()
+	if err != nil {
+		return err
+	}
+	return printMetricsLinks(tmpl, t.root, pods)
+}
+
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	kind, name, _, err := parseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	c, err := tf.connect()
+	if err != nil {
+		return err
+	}
+	api, err := c.findKind(kind)
+	if err != nil {
+		return err
+	}
+	printPlan(api, name, c.ns, c.apis.resources(), tf.maxObjects > 0)
+	return nil
+}
+
+func runEvict(args []string) error {
+	fs := flag.NewFlagSet("evict", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	yes := fs.Bool("yes", false, "evict the pod, otherwise it is only listed")
+	kind, name, rest, err := parseCommand(fs, args, "POD")
+	if err != nil {
+		return err
+	}
+	if err := tf.refuse("evict"); err != nil {
+		return err
+	}
+	return evictPods(tf, kind, name, rest[0], "", false, *yes)
+}
+
+func runDrainNode(args []string) error {
+	fs := flag.NewFlagSet("drain-node", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	cordon := fs.Bool("cordon", false, "also cordon the node")
+	yes := fs.Bool("yes", false, "evict the pods, otherwise they are only listed")
+	kind, name, rest, err := parseCommand(fs, args, "NODE")
+	if err != nil {
+		return err
+	}
+	if err := tf.refuse("drain-node"); err != nil {
+		return err
+	}
+	return evictPods(tf, kind, name, "", rest[0], *cordon, *yes)
+}
+
+func evictPods(tf *treeFlags, kind, name, pod, node string, cordon, confirm bool) error {
+	c, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	pods, err := t.pods()
+	if err != nil {
+		return err
+	}
+	cs, err := c.coreClient()
+	if err != nil {
+		return err
+	}
+	return evictTreePods(cs, pods, pod, node, cordon, confirm)
+}
+
+func runScale(args []string) error {
+	fs := flag.NewFlagSet("scale", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	targetKinds := fs.String("target-kinds", "", "comma separated kinds to limit scaling to, e.g. deployments,statefulsets")
+	wait := fs.Bool("wait", false, "wait until every scaled object reports the new replica count")
+	waitTimeout := fs.Duration("wait-timeout", 5*time.Minute, "how long --wait waits before failing")
+	yes := fs.Bool("yes", false, "scale the objects, otherwise they are only listed")
+	kind, name, rest, err := parseCommand(fs, args, "REPLICAS")
+	if err != nil {
+		return err
+	}
+	replicas, err := strconv.ParseInt(rest[0], 10, 64)
+	if err != nil || replicas < 0 {
+		return fmt.Errorf("invalid replica count %q", rest[0])
+	}
+	if *wait && *waitTimeout <= 0 {
+		return fmt.Errorf("--wait-timeout must be positive")
+	}
+	if err := tf.refuse("scale"); err != nil {
+		return err
+	}
+	c, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	var kinds []string
+	if *targetKinds != "" {
+		kinds = strings.Split(*targetKinds, ",")
+	}
+	targets := findScaleTargets(c.dyn, c.apis, c.ns, t.objs, t.uids, kinds)
+	return scaleTree(c.dyn, targets, c.ns, replicas, *wait, *waitTimeout, *yes)
+}
+
+func runPause(args []string) error  { return pauseCommand("pause", args) }
+func runResume(args []string) error { return pauseCommand("resume", args) }
+
+func pauseCommand(action string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	yes := fs.Bool("yes", false, action+" the objects, otherwise they are only listed")
+	kind, name, _, err := parseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := tf.refuse(action); err != nil {
+		return err
+	}
+	c, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	return pauseTree(c.dyn, c.apis, c.ns, t.objs, t.uids, action == "resume", *yes)
+}
+
+func runCopy(args []string) error {
+	fs := flag.NewFlagSet("cp", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	container := fs.String("container", "", "copy from or to the first container whose name matches this regular expression")
+	fs.StringVar(container, "c", "", "shorthand for --container")
+	kind, name, rest, err := parseCommand(fs, args, "SRC", "DST")
+	if err != nil {
+		return err
+	}
+	containerRE, err := containerRegexp(*container)
+	if err != nil {
+		return err
+	}
+	if _, intoPod := parsePodPath(rest[1]); intoPod {
+		if err := tf.refuse("cp into a pod"); err != nil {
+			return err
+		}
+	}
+	c, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	pods, err := t.pods()
+	if err != nil {
+		return err
+	}
+	cs, err := c.coreClient()
+	if err != nil {
+		return err
+	}
+	return copyFiles(c.config, cs, pods, rest[0], rest[1], containerRE)
+}
+
+func runProbe(args []string) error {
+	fs := flag.NewFlagSet("probe", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	container := fs.String("container", "", "probe from the first container whose name matches this regular expression")
+	fs.StringVar(container, "c", "", "shorthand for --container")
+	image := fs.String("image", "busybox:1.36", "image of the ephemeral container added if the pod lacks the tools (requires --yes)")
+	yes := fs.Bool("yes", false, "add an ephemeral container to the pod if it lacks the tools")
+	kind, name, rest, err := parseCommand(fs, args, "POD", "svc/NAME:PORT|HOST:PORT")
+	if err != nil {
+		return err
+	}
+	containerRE, err := containerRegexp(*container)
+	if err != nil {
+		return err
+	}
+	if *yes {
+		if err := tf.refuse("probe with --yes"); err != nil {
+			return err
+		}
+	}
+	c, t, err := tf.load(kind, name)
+	if err != nil {
+		return err
+	}
+	pods, err := t.pods()
+	if err != nil {
+		return err
+	}
+	cs, err := c.coreClient()
+	if err != nil {
+		return err
+	}
+	return probeFromPod(c.config, cs, pods, rest[0], rest[1], c.ns, containerRE, *image, *yes)
+}
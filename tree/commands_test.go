@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		desc     string
+		args     []string
+		extra    []string
+		wantKind string
+		wantName string
+		wantRest []string
+		wantYes  bool
+		wantErr  bool
+	}{
+		{"flags first", []string{"--yes", "deploy", "web"}, nil, "deploy", "web", []string{}, true, false},
+		{"flags last", []string{"deploy", "web", "--yes"}, nil, "deploy", "web", []string{}, true, false},
+		{"flags between", []string{"deploy", "--yes", "web", "3"}, []string{"REPLICAS"}, "deploy", "web", []string{"3"}, true, false},
+		{"missing argument", []string{"deploy", "web"}, []string{"REPLICAS"}, "", "", nil, false, true},
+		{"extra argument", []string{"deploy", "web", "3"}, nil, "", "", nil, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			yes := fs.Bool("yes", false, "")
+			kind, name, rest, err := parseCommand(fs, tt.args, tt.extra...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCommand(%q) error = %v, want error %v", tt.args, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if kind != tt.wantKind || name != tt.wantName || !reflect.DeepEqual(rest, tt.wantRest) || *yes != tt.wantYes {
+				t.Errorf("parseCommand(%q) = %q, %q, %q, --yes=%v", tt.args, kind, name, rest, *yes)
+			}
+		})
+	}
+}
+
+type recordingTransport struct{ called bool }
+
+func (t *recordingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.called = true
+	return &http.Response{StatusCode: http.StatusOK}, nil
+}
+
+func TestReadOnlyTransport(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{http.MethodGet, "/api/v1/namespaces/shop/pods", true},
+		{http.MethodGet, "/apis/apps/v1/namespaces/shop/deployments?watch=true", true},
+		{http.MethodGet, "/api/v1/namespaces/shop/pods/web-1/log", true},
+		{http.MethodPost, "/api/v1/namespaces/shop/pods/web-1/exec", true},
+		{http.MethodPost, "/prefix/api/v1/namespaces/shop/pods/web-1/exec", true},
+		{http.MethodPost, "/api/v1/namespaces/shop/pods/web-1/eviction", false},
+		{http.MethodPost, "/api/v1/namespaces/shop/configmaps/exec", false},
+		{http.MethodPatch, "/apis/apps/v1/namespaces/shop/deployments/web/scale", false},
+		{http.MethodPatch, "/api/v1/namespaces/shop/pods/web-1/ephemeralcontainers", false},
+		{http.MethodPut, "/apis/apps/v1/namespaces/shop/deployments/web", false},
+		{http.MethodDelete, "/api/v1/namespaces/shop/pods/web-1", false},
+	}
+	for _, tt := range tests {
+		next := &recordingTransport{}
+		req, err := http.NewRequest(tt.method, "https://cluster.example.com"+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = readOnlyTransport{next}.RoundTrip(req)
+		if (err == nil) != tt.want || next.called != tt.want {
+			t.Errorf("%s %s: error = %v, sent = %v, want sent %v", tt.method, tt.path, err, next.called, tt.want)
+		}
+	}
+}
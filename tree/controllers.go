@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+var deploymentsGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+// controllerMap is a repeatable flag mapping kinds to the Deployment of their
+// controller, as KIND=NAMESPACE/NAME.
+type controllerMap map[string]string
+
+func (m controllerMap) String() string {
+	var s []string
+	for k, v := range m {
+		s = append(s, k+"="+v)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (m controllerMap) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i <= 0 || strings.Count(v[i+1:], "/") != 1 || strings.HasPrefix(v[i+1:], "/") || strings.HasSuffix(v, "/") {
+		return fmt.Errorf("%q is not in KIND=NAMESPACE/NAME form", v)
+	}
+	m[strings.ToLower(v[:i])] = v[i+1:]
+	return nil
+}
+
+// controllerPods returns the pods of the controller managing root. Its Deployment is
+// taken from mapping, by kind, or else found by name among the field managers that
+// wrote root's status: a Deployment in any namespace named after one, or labelled
+// app.kubernetes.io/name with it.
+func controllerPods(dyn dynamic.Interface, client corev1client.PodsGetter, root unstructured.Unstructured, mapping controllerMap) ([]corev1.Pod, error) {
+	var deployments []unstructured.Unstructured
+	if ref, ok := mapping[strings.ToLower(root.GetKind())]; ok {
+		ns, name, _ := strings.Cut(ref, "/")
+		d, err := dyn.Resource(deploymentsGVR).Namespace(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get controller of %s: %w", root.GetKind(), err)
+		}
+		deployments = append(deployments, *d)
+	} else {
+		managers := statusManagers(root)
+		if len(managers) == 0 {
+			return nil, fmt.Errorf("no field manager wrote the status of %s, give its controller with --controller", displayName(root))
+		}
+		list, err := dyn.Resource(deploymentsGVR).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deployments: %w", err)
+		}
+		for _, d := range list.Items {
+			if managers[d.GetName()] || managers[d.GetLabels()["app.kubernetes.io/name"]] {
+				deployments = append(deployments, d)
+			}
+		}
+		if len(deployments) == 0 {
+			var names []string
+			for m := range managers {
+				names = append(names, m)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("no deployment found for the managers of %s (%s), give its controller with --controller",
+				displayName(root), strings.Join(names, ", "))
+		}
+	}
+
+	var out []corev1.Pod
+	for _, d := range deployments {
+		sel, _, _ := unstructured.NestedFieldNoCopy(d.Object, "spec", "selector")
+		var ls metav1.LabelSelector
+		if m, ok := sel.(map[string]interface{}); ok {
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, &ls); err != nil {
+				return nil, fmt.Errorf("invalid selector of deployment %s: %w", d.GetName(), err)
+			}
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&ls)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		pods, err := client.Pods(d.GetNamespace()).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods of deployment %s: %w", d.GetName(), err)
+		}
+		for _, p := range pods.Items {
+			if p.Status.Phase == corev1.PodRunning {
+				out = append(out, p)
+			}
+		}
+	}
+	return out, nil
+}
+
+// statusManagers returns the names of the field managers that wrote the status of o,
+// leaving out kubectl.
+func statusManagers(o unstructured.Unstructured) map[string]bool {
+	out := make(map[string]bool)
+	for _, mf := range o.GetManagedFields() {
+		if strings.HasPrefix(mf.Manager, "kubectl") {
+			continue
+		}
+		if mf.Subresource == "status" || mf.FieldsV1 != nil && strings.Contains(string(mf.FieldsV1.Raw), `"f:status"`) {
+			out[mf.Manager] = true
+		}
+	}
+	return out
+}
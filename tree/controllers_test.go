@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestControllerMapSet(t *testing.T) {
+	tests := []struct {
+		v       string
+		wantErr bool
+	}{
+		{"Certificate=cert-manager/cert-manager", false},
+		{"certificate", true},
+		{"Certificate=cert-manager", true},
+		{"Certificate=/cert-manager", true},
+		{"Certificate=cert-manager/", true},
+		{"Certificate=a/b/c", true},
+		{"=cert-manager/cert-manager", true},
+	}
+	for _, tt := range tests {
+		m := controllerMap{}
+		if err := m.Set(tt.v); (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q) error = %v, want error %v", tt.v, err, tt.wantErr)
+		}
+	}
+	m := controllerMap{}
+	_ = m.Set("Certificate=cert-manager/cert-manager")
+	if got := m["certificate"]; got != "cert-manager/cert-manager" {
+		t.Errorf("kinds are not looked up case-insensitively: %v", m)
+	}
+}
+
+func TestStatusManagers(t *testing.T) {
+	o := testObject("web")
+	o.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-client-side-apply", Operation: metav1.ManagedFieldsOperationUpdate,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{},"f:status":{}}`)}},
+		{Manager: "helm", Operation: metav1.ManagedFieldsOperationUpdate,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)}},
+		{Manager: "cert-manager-certificates-readiness", Operation: metav1.ManagedFieldsOperationUpdate, Subresource: "status",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:conditions":{}}`)}},
+		{Manager: "my-operator", Operation: metav1.ManagedFieldsOperationUpdate,
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:status":{"f:phase":{}}}`)}},
+	})
+	want := map[string]bool{"cert-manager-certificates-readiness": true, "my-operator": true}
+	if got := statusManagers(o); !reflect.DeepEqual(got, want) {
+		t.Errorf("statusManagers() = %v, want %v", got, want)
+	}
+}
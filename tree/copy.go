@@ -0,0 +1,243 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// podPath is a POD:PATH argument of cp.
+type podPath struct {
+	pod, path string
+}
+
+// parsePodPath splits s into a pod and a path if it has the form POD:PATH.
+func parsePodPath(s string) (podPath, bool) {
+	i := strings.Index(s, ":")
+	if i <= 0 || strings.ContainsAny(s[:i], `/\`) {
+		return podPath{}, false
+	}
+	return podPath{pod: s[:i], path: s[i+1:]}, true
+}
+
+// copyFiles copies between a pod of the tree and the local filesystem by running tar
+// in the pod, the same way kubectl cp does. Exactly one of src and dst must be POD:PATH.
+// The first container matching container is used, or the first container if it is nil.
+func copyFiles(config *rest.Config, client corev1client.CoreV1Interface, pods []corev1.Pod, src, dst string, container *regexp.Regexp) error {
+	from, remoteSrc := parsePodPath(src)
+	to, remoteDst := parsePodPath(dst)
+	if remoteSrc == remoteDst {
+		return fmt.Errorf("exactly one of SRC and DST must be POD:PATH")
+	}
+	remote := from
+	if remoteDst {
+		remote = to
+	}
+
+	var pod *corev1.Pod
+	for i := range pods {
+		if pods[i].Name == remote.pod {
+			pod = &pods[i]
+		}
+	}
+	if pod == nil {
+		return fmt.Errorf("pod %q is not part of the tree", remote.pod)
+	}
+	c, err := execContainer(pod, container)
+	if err != nil {
+		return err
+	}
+
+	if remoteSrc {
+		dir, base := path.Split(path.Clean(from.path))
+		if dir == "" {
+			dir = "."
+		}
+		// the archive is extracted while it is read, files such as heap dumps can be
+		// larger than what fits in memory
+		pr, pw := io.Pipe()
+		go func() {
+			var stderr bytes.Buffer
+			err := podExec(config, client, pod, c, []string{"tar", "cf", "-", "-C", dir, base}, nil, pw, &stderr)
+			if err != nil {
+				err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+			pw.CloseWithError(err)
+		}()
+		err := untar(pr, base, dst)
+		if err == nil {
+			// the end of the archive may come before tar in the pod has exited
+			_, err = io.Copy(io.Discard, pr)
+		}
+		pr.CloseWithError(err)
+		if err != nil {
+			return fmt.Errorf("failed to copy from %s: %w", src, err)
+		}
+		return nil
+	}
+
+	dir, base := path.Split(to.path)
+	if base == "" {
+		base = filepath.Base(src)
+	}
+	if dir == "" {
+		dir = "."
+	}
+	pr, pw := io.Pipe()
+	tarErr := make(chan error, 1)
+	go func() {
+		err := tarPath(pw, src, base)
+		pw.CloseWithError(err)
+		tarErr <- err
+	}()
+	var stderr bytes.Buffer
+	err = podExec(config, client, pod, c, []string{"tar", "xf", "-", "-C", dir}, pr, io.Discard, &stderr)
+	// unblock tarPath if the exec ended before reading all of its input
+	pr.Close()
+	if err != nil {
+		return fmt.Errorf("failed to copy to %s: %w: %s", dst, err, strings.TrimSpace(stderr.String()))
+	}
+	if err := <-tarErr; err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	return nil
+}
+
+// execContainer returns the first container of pod matching container, or the first
+// container if it is nil.
+func execContainer(pod *corev1.Pod, container *regexp.Regexp) (string, error) {
+	for _, c := range pod.Spec.Containers {
+		if container == nil || container.MatchString(c.Name) {
+			return c.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no container of pod %s matches the container filter", pod.Name)
+}
+
+// podExec runs cmd in container c of pod.
+func podExec(config *rest.Config, client corev1client.CoreV1Interface, pod *corev1.Pod, c string, cmd []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	req := client.RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: c,
+			Command:   cmd,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	return exec.Stream(remotecommand.StreamOptions{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+}
+
+// tarPath writes the file or directory at src to w as a tar archive, named name in it.
+func tarPath(w io.Writer, src, name string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// archiveRel returns the path of an archive entry relative to base, which must be the
+// entry itself or one of its parent directories. Paths are compared by whole segments
+// after cleaning, so neither "basex/y" nor "base/../y" is under base.
+func archiveRel(name, base string) (string, bool) {
+	name = path.Clean(name)
+	switch {
+	case name == base:
+		return "", true
+	case strings.HasPrefix(name, base+"/"):
+		return name[len(base)+1:], true
+	default:
+		return "", false
+	}
+}
+
+// untar extracts the archive in r, whose entries are under base, to dst. Like cp, if dst
+// is an existing directory the entries are placed in it, otherwise base is renamed to dst.
+// Entries that are not regular files or directories are skipped.
+func untar(r io.Reader, base, dst string) error {
+	if fi, err := os.Stat(dst); err == nil && fi.IsDir() {
+		dst = filepath.Join(dst, base)
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		rel, ok := archiveRel(hdr.Name, base)
+		if !ok {
+			return fmt.Errorf("archive entry %q is outside of %s", hdr.Name, base)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
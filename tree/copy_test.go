@@ -0,0 +1,98 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveRel(t *testing.T) {
+	tests := []struct {
+		name, base string
+		want       string
+		ok         bool
+	}{
+		{"foo", "foo", "", true},
+		{"foo/", "foo", "", true},
+		{"foo/x", "foo", "x", true},
+		{"foo/a/b", "foo", "a/b", true},
+		{"./foo/x", "foo", "x", true},
+		{"foobar/x", "foo", "", false},
+		{"fo/x", "foo", "", false},
+		{"foo/../x", "foo", "", false},
+		{"foo/../../x", "foo", "", false},
+		{"../foo/x", "foo", "", false},
+		{"/foo/x", "foo", "", false},
+		{"x", "foo", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := archiveRel(tt.name, tt.base)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("archiveRel(%q, %q) = %q, %v, want %q, %v", tt.name, tt.base, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// testArchive returns a tar archive with a regular file for every name.
+func testArchive(t *testing.T, names ...string) *bytes.Buffer {
+	t.Helper()
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, n := range names {
+		content := []byte("content of " + n)
+		if err := tw.WriteHeader(&tar.Header{Name: n, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &b
+}
+
+func TestUntar(t *testing.T) {
+	tests := []struct {
+		desc    string
+		entries []string
+		dstDir  bool // whether dst exists as a directory
+		want    []string
+		wantErr bool
+	}{
+		{desc: "into new path", entries: []string{"dump/heap.hprof", "dump/sub/x"}, want: []string{"out/heap.hprof", "out/sub/x"}},
+		{desc: "into existing dir", entries: []string{"dump/heap.hprof"}, dstDir: true, want: []string{"out/dump/heap.hprof"}},
+		{desc: "single file", entries: []string{"dump"}, want: []string{"out"}},
+		{desc: "sibling with base as prefix", entries: []string{"dumpster/x"}, wantErr: true},
+		{desc: "parent traversal", entries: []string{"dump/../../escape"}, wantErr: true},
+		{desc: "absolute path", entries: []string{"/etc/passwd"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			root := t.TempDir()
+			dst := filepath.Join(root, "out")
+			if tt.dstDir {
+				if err := os.Mkdir(dst, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := untar(testArchive(t, tt.entries...), "dump", dst)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("untar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, w := range tt.want {
+				if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(w))); err != nil {
+					t.Errorf("expected %s to be extracted: %v", w, err)
+				}
+			}
+			if tt.wantErr {
+				if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escape")); err == nil {
+					t.Errorf("entry was extracted outside of the destination")
+				}
+			}
+		})
+	}
+}
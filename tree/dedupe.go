@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// dedupeIdle is how long a held back line waits for a repeat before it is written.
+const dedupeIdle = time.Second
+
+// deduper collapses identical consecutive lines of the same container into one line
+// with a repeat count. A line is held back until a different line of its container
+// arrives, it has not repeated for dedupeIdle, or flush is called.
+type deduper struct {
+	next    lineWriter
+	pending map[string]*heldLine // by pod UID and container
+	seq     int
+}
+
+// heldLine is a line held back by a deduper.
+type heldLine struct {
+	line logLine
+	seen time.Time // when the line last repeated
+	seq  int       // arrival order, lines are written in it
+}
+
+func newDeduper(next lineWriter) *deduper {
+	return &deduper{next: next, pending: make(map[string]*heldLine)}
+}
+
+func (d *deduper) write(l logLine) error {
+	key := string(l.pod.UID) + "/" + l.container
+	if p := d.pending[key]; p != nil {
+		if p.line.text == l.text {
+			p.line.repeats++
+			p.seen = time.Now()
+			return nil
+		}
+		delete(d.pending, key)
+		if err := d.next.write(p.line); err != nil {
+			return err
+		}
+	}
+	l.repeats = 1
+	d.seq++
+	d.pending[key] = &heldLine{line: l, seen: time.Now(), seq: d.seq}
+	return nil
+}
+
+// flushIdle writes the held back lines that have not repeated for idle.
+func (d *deduper) flushIdle(idle time.Duration) error {
+	return d.flushIf(func(h *heldLine) bool { return time.Since(h.seen) >= idle })
+}
+
+// flush writes all held back lines.
+func (d *deduper) flush() error {
+	return d.flushIf(func(*heldLine) bool { return true })
+}
+
+// flushIf writes the held back lines for which f is true, in arrival order.
+func (d *deduper) flushIf(f func(*heldLine) bool) error {
+	var keys []string
+	for key, h := range d.pending {
+		if f(h) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return d.pending[keys[i]].seq < d.pending[keys[j]].seq })
+	for _, key := range keys {
+		h := d.pending[key]
+		delete(d.pending, key)
+		if err := d.next.write(h.line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run writes lines to d until lines is closed or the process is interrupted, writing
+// idle lines as it goes and all held back lines at the end.
+func (d *deduper) run(lines <-chan logLine) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	tick := time.NewTicker(dedupeIdle / 2)
+	defer tick.Stop()
+	for {
+		select {
+		case l, ok := <-lines:
+			if !ok {
+				return d.flush()
+			}
+			if err := d.write(l); err != nil {
+				return err
+			}
+		case <-tick.C:
+			if err := d.flushIdle(dedupeIdle); err != nil {
+				return err
+			}
+		case <-sig:
+			return d.flush()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// lineRecorder is a lineWriter that keeps what is written to it.
+type lineRecorder []string
+
+func (r *lineRecorder) write(l logLine) error {
+	*r = append(*r, fmt.Sprintf("%s/%s %s x%d", l.pod.Name, l.container, l.text, l.repeats))
+	return nil
+}
+
+func TestDeduper(t *testing.T) {
+	a := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a", UID: "a"}}
+	b := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "b", UID: "b"}}
+	in := []logLine{
+		{pod: a, container: "app", text: "ping"},
+		{pod: b, container: "app", text: "ping"},
+		{pod: a, container: "app", text: "ping"},
+		{pod: a, container: "app", text: "ping"},
+		{pod: a, container: "sidecar", text: "ping"},
+		{pod: a, container: "app", text: "pong"},
+		{pod: b, container: "app", text: "ping"},
+		{pod: a, container: "app", text: "ping"},
+	}
+
+	var got lineRecorder
+	d := newDeduper(&got)
+	for _, l := range in {
+		if err := d.write(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		// written when pong arrives
+		"a/app ping x3",
+		"a/app pong x1",
+		// the rest is held until flush, which writes in arrival order
+		"b/app ping x2",
+		"a/sidecar ping x1",
+		"a/app ping x1",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestDeduperFlushIdle(t *testing.T) {
+	a := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "a", UID: "a"}}
+	var got lineRecorder
+	d := newDeduper(&got)
+	if err := d.write(logLine{pod: a, container: "app", text: "last words"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.flushIdle(0); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "a/app last words x1" {
+		t.Errorf("got %q, want the held line to be written", got)
+	}
+	if len(d.pending) != 0 {
+		t.Errorf("%d lines are still held", len(d.pending))
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+var eventsGVR = schema.GroupVersionResource{Version: "v1", Resource: "events"}
+
+// eventRepeatInterval is how often a repeating event series is printed again.
+const eventRepeatInterval = time.Minute
+
+// streamEvents watches Events in the tree's namespace and prints the ones whose
+// involvedObject is in the tree, including objects that joined it after startup.
+// Existing events are printed first, then new ones as they arrive. Repeats of the
+// same reason for the same object are collapsed, see eventSeries. If probes is not nil,
+// failed probes are counted in it and summarised on SIGINT or SIGTERM.
+func streamEvents(client dynamic.Interface, tree *treeIndex, eventType string, probes probeFailures) error {
+	opts := metav1.ListOptions{}
+	if eventType != "" {
+		opts.FieldSelector = fields.OneTermEqualSelector("type", eventType).String()
+	}
+	series := make(map[string]*eventSeries)
+	tick := time.NewTicker(eventRepeatInterval)
+	defer tick.Stop()
+	var sig chan os.Signal
+	if probes != nil {
+		sig = make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sig)
+	}
+
+	for {
+		w, err := client.Resource(eventsGVR).Namespace(tree.ns).Watch(context.TODO(), opts)
+		if err != nil {
+			return fmt.Errorf("failed to watch events: %w", err)
+		}
+	watchLoop:
+		for {
+			select {
+			case <-tick.C:
+				printPendingSeries(series)
+			case <-sig:
+				w.Stop()
+				printPendingSeries(series)
+				fmt.Println()
+				return probes.print(func(pod, container string) string {
+					return containerRestarts(client, tree.ns, pod, container)
+				})
+			case ev, ok := <-w.ResultChan():
+				if !ok {
+					break watchLoop
+				}
+				if ev.Type == watch.Error {
+					// most likely an expired resourceVersion, start over; series that
+					// were already printed are not printed again
+					opts.ResourceVersion = ""
+					break watchLoop
+				}
+				u, ok := ev.Object.(*unstructured.Unstructured)
+				if !ok {
+					continue
+				}
+				opts.ResourceVersion = u.GetResourceVersion()
+				if ev.Type != watch.Added && ev.Type != watch.Modified {
+					continue
+				}
+				ref := involvedObject(u)
+				if !tree.containsRef(ref) {
+					continue
+				}
+				key := string(ref.UID) + "/" + eventReason(u)
+				s := series[key]
+				if s == nil {
+					s = &eventSeries{counts: make(map[types.UID]int64)}
+					series[key] = s
+				}
+				s.observe(u)
+				if probes != nil && ref.Kind == "Pod" {
+					probes.observe(u)
+				}
+				if s.printed.IsZero() || time.Since(s.printed) >= eventRepeatInterval {
+					s.print()
+				}
+			}
+		}
+		// the server closes watches periodically, resume from the last seen version
+		w.Stop()
+	}
+}
+
+// eventSeries collects the Events with the same reason for the same object. Its first
+// occurrence is printed right away; further occurrences at most every
+// eventRepeatInterval, annotated with how often the series occurred and over how long.
+type eventSeries struct {
+	latest       *unstructured.Unstructured
+	counts       map[types.UID]int64 // count of each Event object in the series
+	first        time.Time
+	printed      time.Time // when the series was last printed
+	printedCount int64     // total count when the series was last printed
+}
+
+func (s *eventSeries) observe(u *unstructured.Unstructured) {
+	s.latest = u
+	s.counts[u.GetUID()] = eventCount(u)
+	if t := eventFirstTime(u); s.first.IsZero() || t.Before(s.first) {
+		s.first = t
+	}
+}
+
+func (s *eventSeries) total() int64 {
+	var n int64
+	for _, c := range s.counts {
+		n += c
+	}
+	return n
+}
+
+// print prints the latest event of s if the series grew since it was last printed.
+func (s *eventSeries) print() {
+	total := s.total()
+	if total <= s.printedCount {
+		return
+	}
+	line := formatEvent(s.latest)
+	if total > 1 {
+		line += fmt.Sprintf(" (x%d in last %s)", total, duration.HumanDuration(time.Since(s.first)))
+	}
+	fmt.Println(line)
+	s.printed = time.Now()
+	s.printedCount = total
+}
+
+// printPendingSeries prints the series that grew since they were last printed, so
+// the final count of a series that stopped repeating is shown too.
+func printPendingSeries(series map[string]*eventSeries) {
+	var pending []*eventSeries
+	for _, s := range series {
+		if s.total() > s.printedCount {
+			pending = append(pending, s)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].printed.Before(pending[j].printed) })
+	for _, s := range pending {
+		s.print()
+	}
+}
+
+// eventCount returns how often an Event occurred, from series.count for events
+// recorded through events.k8s.io/v1 and count for core ones.
+func eventCount(u *unstructured.Unstructured) int64 {
+	if n, ok, _ := unstructured.NestedInt64(u.Object, "series", "count"); ok && n > 0 {
+		return n
+	}
+	if n, ok, _ := unstructured.NestedInt64(u.Object, "count"); ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// eventFirstTime returns when an Event first occurred.
+func eventFirstTime(u *unstructured.Unstructured) time.Time {
+	for _, f := range []string{"firstTimestamp", "eventTime"} {
+		if ts, _, _ := unstructured.NestedString(u.Object, f); ts != "" {
+			if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				return t
+			}
+		}
+	}
+	return u.GetCreationTimestamp().Time
+}
+
+func eventReason(u *unstructured.Unstructured) string {
+	reason, _, _ := unstructured.NestedString(u.Object, "reason")
+	return reason
+}
+
+// involvedObject returns a reference to the object an Event is about.
+func involvedObject(u *unstructured.Unstructured) metav1.OwnerReference {
+	apiVersion, _, _ := unstructured.NestedString(u.Object, "involvedObject", "apiVersion")
+	kind, _, _ := unstructured.NestedString(u.Object, "involvedObject", "kind")
+	name, _, _ := unstructured.NestedString(u.Object, "involvedObject", "name")
+	uid, _, _ := unstructured.NestedString(u.Object, "involvedObject", "uid")
+	return metav1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID(uid)}
+}
+
+// formatEvent renders an Event as a single line similar to `kubectl get events`.
+func formatEvent(u *unstructured.Unstructured) string {
+	typ, _, _ := unstructured.NestedString(u.Object, "type")
+	reason, _, _ := unstructured.NestedString(u.Object, "reason")
+	message, _, _ := unstructured.NestedString(u.Object, "message")
+	kind, _, _ := unstructured.NestedString(u.Object, "involvedObject", "kind")
+	name, _, _ := unstructured.NestedString(u.Object, "involvedObject", "name")
+
+	ts, _, _ := unstructured.NestedString(u.Object, "series", "lastObservedTime")
+	if ts == "" {
+		ts, _, _ = unstructured.NestedString(u.Object, "lastTimestamp")
+	}
+	if ts == "" {
+		ts, _, _ = unstructured.NestedString(u.Object, "eventTime")
+	}
+	if ts == "" {
+		ts = u.GetCreationTimestamp().UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s/%s\t%s", ts, typ, reason, kind, name, message)
+}
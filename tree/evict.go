@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// evictTreePods evicts the pod named pod, or all pods of the tree scheduled on node,
+// through the Eviction API so PodDisruptionBudgets are respected. With cordon the node
+// is marked unschedulable first. Nothing is changed unless confirm is set; the pods
+// that would be evicted are listed instead.
+func evictTreePods(client corev1client.CoreV1Interface, pods []corev1.Pod, pod, node string, cordon, confirm bool) error {
+	var targets []corev1.Pod
+	for _, p := range pods {
+		if (pod != "" && p.Name == pod) || (node != "" && p.Spec.NodeName == node) {
+			targets = append(targets, p)
+		}
+	}
+	if pod != "" && len(targets) == 0 {
+		return fmt.Errorf("pod %q is not part of the tree", pod)
+	}
+
+	if !confirm {
+		if cordon {
+			fmt.Printf("would cordon node/%s\n", node)
+		}
+		for _, p := range targets {
+			fmt.Printf("would evict pod/%s\n", p.Name)
+		}
+		fmt.Println("Nothing was changed, run again with --yes to proceed.")
+		return nil
+	}
+
+	if cordon {
+		patch := []byte(`{"spec":{"unschedulable":true}}`)
+		if _, err := client.Nodes().Patch(context.TODO(), node, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("failed to cordon node %s: %w", node, err)
+		}
+		fmt.Printf("cordoned node/%s\n", node)
+	}
+	for _, p := range targets {
+		err := client.Pods(p.Namespace).EvictV1(context.TODO(), &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: p.Name, Namespace: p.Namespace},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to evict pod %s: %w", p.Name, err)
+		}
+		fmt.Printf("evicted pod/%s\n", p.Name)
+	}
+	if len(targets) == 0 {
+		fmt.Printf("No pods of the tree are running on node %s.\n", node)
+	}
+	return nil
+}
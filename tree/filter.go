@@ -0,0 +1,128 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// regexpList is a repeatable flag of regular expressions.
+type regexpList []*regexp.Regexp
+
+func (l *regexpList) String() string {
+	var s []string
+	for _, re := range *l {
+		s = append(s, re.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *regexpList) Set(v string) error {
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, re)
+	return nil
+}
+
+// redaction is a --redact rule: matches of re are masked, unless valid is set and
+// rejects the match.
+type redaction struct {
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+// redactionPresets are named rules accepted by --redact in place of a regular expression.
+var redactionPresets = map[string]redaction{
+	"bearer": {re: regexp.MustCompile(`(?i)bearer\s+[a-z0-9\-._~+/]+=*`)},
+	"email":  {re: regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)},
+	// only numbers with a valid check digit, so that timestamps and IDs are left alone
+	"card": {re: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), valid: luhnValid},
+}
+
+// redactList is a repeatable flag of redactions, given as regular expressions or the
+// names of redactionPresets.
+type redactList []redaction
+
+func (l *redactList) String() string {
+	var s []string
+	for _, r := range *l {
+		s = append(s, r.re.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *redactList) Set(v string) error {
+	if p, ok := redactionPresets[v]; ok {
+		*l = append(*l, p)
+		return nil
+	}
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, redaction{re: re})
+	return nil
+}
+
+// redacted replaces redacted text and field values.
+const redacted = "[REDACTED]"
+
+// redact replaces every match of rules in s.
+func redact(s string, rules []redaction) string {
+	for _, r := range rules {
+		if r.valid == nil {
+			s = r.re.ReplaceAllString(s, redacted)
+			continue
+		}
+		s = r.re.ReplaceAllStringFunc(s, func(m string) string {
+			if r.valid(m) {
+				return redacted
+			}
+			return m
+		})
+	}
+	return s
+}
+
+// luhnValid reports whether the digits in s, ignoring spaces and dashes, pass the Luhn
+// check used by payment card numbers.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// keep reports whether a line passes the include and exclude filters of opts.
+func (opts logOptions) keep(s string) bool {
+	for _, re := range opts.exclude {
+		if re.MatchString(s) {
+			return false
+		}
+	}
+	if len(opts.include) == 0 {
+		return true
+	}
+	for _, re := range opts.include {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestRedactPresets(t *testing.T) {
+	tests := []struct {
+		preset string
+		s      string
+		want   string
+	}{
+		{"bearer", "Authorization: Bearer abc.DEF-123_~+/==", "Authorization: [REDACTED]"},
+		{"bearer", "bearer token rotated", "[REDACTED] rotated"},
+		{"bearer", "no credentials here", "no credentials here"},
+		{"email", "sent to jane.doe+ops@example.co.uk today", "sent to [REDACTED] today"},
+		{"email", "user@localhost", "user@localhost"},
+		{"card", "card 4111 1111 1111 1111 charged", "card [REDACTED] charged"},
+		{"card", "card 4111-1111-1111-1111", "card [REDACTED]"},
+		{"card", "card 4111111111111111", "card [REDACTED]"},
+		// fails the Luhn check
+		{"card", "order 4111111111111112", "order 4111111111111112"},
+		{"card", "ts 1654041600123456", "ts 1654041600123456"},
+		// too short to be a card number
+		{"card", "id 79927398713", "id 79927398713"},
+	}
+	for _, tt := range tests {
+		var l redactList
+		if err := l.Set(tt.preset); err != nil {
+			t.Fatal(err)
+		}
+		if got := redact(tt.s, l); got != tt.want {
+			t.Errorf("redact(%q) with %s = %q, want %q", tt.s, tt.preset, got, tt.want)
+		}
+	}
+}
+
+func TestRedactRegexp(t *testing.T) {
+	var l redactList
+	for _, v := range []string{`password=\S+`, "email"} {
+		if err := l.Set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := "login password=hunter2 for ops@example.com"
+	if got, want := redact(s, l), "login [REDACTED] for [REDACTED]"; got != want {
+		t.Errorf("redact(%q) = %q, want %q", s, got, want)
+	}
+	if err := l.Set("("); err == nil {
+		t.Error("invalid regular expression accepted")
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"79927398713", true},
+		{"79927398710", false},
+		{"4111 1111 1111 1111", true},
+		{"5500-0000-0000-0004", true},
+		{"", false},
+		{"12a4", false},
+	}
+	for _, tt := range tests {
+		if got := luhnValid(tt.s); got != tt.want {
+			t.Errorf("luhnValid(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
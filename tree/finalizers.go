@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// printTerminating lists objects in the tree that have a deletionTimestamp, how long
+// they have been terminating and which finalizers are still holding them.
+func printTerminating(objs objectDirectory, uids map[types.UID]bool) error {
+	var out []unstructured.Unstructured
+	for uid := range uids {
+		if o, ok := objs.items[uid]; ok && o.GetDeletionTimestamp() != nil {
+			out = append(out, o)
+		}
+	}
+	if len(out) == 0 {
+		fmt.Println("No objects in the tree are being deleted.")
+		return nil
+	}
+	// longest terminating first
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].GetDeletionTimestamp().Before(out[j].GetDeletionTimestamp())
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "OBJECT\tTERMINATING FOR\tFINALIZERS")
+	for _, o := range out {
+		finalizers := strings.Join(o.GetFinalizers(), ",")
+		if finalizers == "" {
+			finalizers = "<none>"
+		}
+		age := time.Since(o.GetDeletionTimestamp().Time).Round(time.Second)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", displayName(o), age, finalizers)
+	}
+	return w.Flush()
+}
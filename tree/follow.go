@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// maxOwnerDepth bounds how far ownerReferences are followed when resolving new objects.
+const maxOwnerDepth = 16
+
+// treeIndex decides whether objects created after the initial sweep belong to the tree
+// by following their ownerReferences up to a known member, fetching unknown owners.
+type treeIndex struct {
+	client  dynamic.Interface
+	apis    *resourceMap
+	ns      string
+	members map[types.UID]bool
+	outside map[types.UID]bool
+}
+
+func newTreeIndex(client dynamic.Interface, apis *resourceMap, ns string, objs objectDirectory, members map[types.UID]bool) *treeIndex {
+	outside := make(map[types.UID]bool)
+	for uid := range objs.items {
+		if !members[uid] {
+			outside[uid] = true
+		}
+	}
+	return &treeIndex{
+		client:  client,
+		apis:    apis,
+		ns:      ns,
+		members: members,
+		outside: outside,
+	}
+}
+
+// contains reports whether the object with uid and refs as its ownerReferences is in the tree.
+func (t *treeIndex) contains(uid types.UID, refs []metav1.OwnerReference) bool {
+	in, _ := t.resolve(uid, refs, 0)
+	return in
+}
+
+// containsRef reports whether the object ref points to is in the tree, fetching it if
+// it was not seen before.
+func (t *treeIndex) containsRef(ref metav1.OwnerReference) bool {
+	if t.members[ref.UID] {
+		return true
+	}
+	if t.outside[ref.UID] {
+		return false
+	}
+	o, err := t.getOwner(ref)
+	if err != nil || o.GetUID() != ref.UID {
+		return false
+	}
+	return t.contains(o.GetUID(), o.GetOwnerReferences())
+}
+
+// resolve reports whether the object with uid is in the tree, and whether that is known
+// for certain. An object is only remembered as outside of the tree once every one of its
+// owners was found outside; owners that could not be fetched are tried again next time.
+func (t *treeIndex) resolve(uid types.UID, refs []metav1.OwnerReference, depth int) (in, known bool) {
+	if t.members[uid] {
+		return true, true
+	}
+	if t.outside[uid] {
+		return false, true
+	}
+	if depth > maxOwnerDepth {
+		return false, false
+	}
+	known = true
+	for _, ref := range refs {
+		if t.members[ref.UID] {
+			t.members[uid] = true
+			return true, true
+		}
+		if t.outside[ref.UID] {
+			continue
+		}
+		owner, err := t.getOwner(ref)
+		if err != nil {
+			known = false
+			continue
+		}
+		if owner.GetUID() != ref.UID {
+			// the owner is gone and its name was reused
+			continue
+		}
+		ownerIn, ownerKnown := t.resolve(owner.GetUID(), owner.GetOwnerReferences(), depth+1)
+		if ownerIn {
+			t.members[uid] = true
+			return true, true
+		}
+		known = known && ownerKnown
+	}
+	if known {
+		t.outside[uid] = true
+	}
+	return false, known
+}
+
+func (t *treeIndex) getOwner(ref metav1.OwnerReference) (metav1.Object, error) {
+	api, err := t.apis.forKind(ref.APIVersion, ref.Kind)
+	if err != nil {
+		return nil, err
+	}
+	return resourceInterface(t.client, api, t.ns).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+}
+
+// followLogs streams the logs of the pods in the tree and keeps watching the namespace,
+// opening streams for pods that join the tree and closing them for deleted pods.
+// Changes of root are written into the stream as they happen, and the logs of extra
+// pods, which are not part of the tree, are followed too.
+func followLogs(client corev1client.PodsGetter, tree *treeIndex, root unstructured.Unstructured, extra []corev1.Pod, opts logOptions, w lineWriter) error {
+	s := newLogStreamer(client, opts)
+	for i := range extra {
+		for _, c := range opts.startedContainers(&extra[i]) {
+			s.start(&extra[i], c)
+		}
+	}
+	errc := make(chan error, 2)
+	go func() { errc <- watchTreePods(client, tree, s) }()
+	go func() {
+		// logs are still worth following without the changes of the root
+		if err := watchRoot(tree, root, s); err != nil {
+			fmt.Fprintf(os.Stderr, "not reporting changes of %s: %v\n", displayName(root), err)
+		}
+	}()
+	go func() { errc <- s.write(w) }()
+	return <-errc
+}
+
+// watchTreePods starts a stream for every started container of pods in the tree, including
+// new instances of restarted containers, until the watch fails.
+func watchTreePods(client corev1client.PodsGetter, tree *treeIndex, s *logStreamer) error {
+	var rv string
+	for {
+		w, err := client.Pods(tree.ns).Watch(context.TODO(), metav1.ListOptions{ResourceVersion: rv})
+		if err != nil {
+			return fmt.Errorf("failed to watch pods: %w", err)
+		}
+		for ev := range w.ResultChan() {
+			if ev.Type == watch.Error {
+				// most likely an expired resourceVersion, start over; streams that
+				// are already open are not opened again
+				rv = ""
+				break
+			}
+			pod, ok := ev.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+			rv = pod.ResourceVersion
+			if ev.Type == watch.Deleted {
+				s.stop(pod.UID)
+				continue
+			}
+			if !tree.contains(pod.UID, pod.OwnerReferences) || !s.opts.podStates.keep(pod.Status.Phase, pod.Status.Reason) {
+				continue
+			}
+			for _, c := range s.opts.startedContainers(pod) {
+				s.start(pod, c)
+			}
+		}
+		// the server closes watches periodically, resume from the last seen version
+		w.Stop()
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestTreeIndexRetriesFailedOwners(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMaps: "ConfigMapList"})
+	apis := &resourceMap{m: resourceNameLookup{"configmap.v1.": {
+		{r: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap", Namespaced: true}, gv: schema.GroupVersion{Version: "v1"}},
+	}}}
+	root := testObject("root")
+	tree := newTreeIndex(client, apis, "default",
+		newObjectDirectory([]unstructured.Unstructured{root}), map[types.UID]bool{"root": true})
+
+	// the owner of child is not there yet, so whether child is in the tree is unknown
+	child := testObject("child", "parent")
+	if tree.contains(child.GetUID(), child.GetOwnerReferences()) {
+		t.Fatal("child with a missing owner is in the tree")
+	}
+	if tree.outside[child.GetUID()] {
+		t.Fatal("child with a missing owner was remembered as outside of the tree")
+	}
+
+	parent := testObject("parent", "root")
+	parent.SetNamespace("default")
+	if _, err := client.Resource(configMaps).Namespace("default").Create(context.TODO(), &parent, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !tree.contains(child.GetUID(), child.GetOwnerReferences()) {
+		t.Error("child is not in the tree once its owner can be fetched")
+	}
+
+	// an owner that was fetched and is outside of the tree is remembered
+	other := testObject("other")
+	other.SetNamespace("default")
+	if _, err := client.Resource(configMaps).Namespace("default").Create(context.TODO(), &other, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	stranger := testObject("stranger", "other")
+	if tree.contains(stranger.GetUID(), stranger.GetOwnerReferences()) || !tree.outside[stranger.GetUID()] {
+		t.Error("object owned outside of the tree was not remembered as outside")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var deletionPolicies = map[string]metav1.DeletionPropagation{
+	"background": metav1.DeletePropagationBackground,
+	"foreground": metav1.DeletePropagationForeground,
+	"orphan":     metav1.DeletePropagationOrphan,
+}
+
+// simulateDeletion returns the UIDs of the objects the garbage collector would remove
+// if root was deleted with the given propagation policy. An object is only removed
+// once all of its owners are removed; owners that are not in the directory (e.g.
+// cluster-scoped objects) are assumed to stay.
+func simulateDeletion(root types.UID, objs objectDirectory, policy metav1.DeletionPropagation) map[types.UID]bool {
+	removed := map[types.UID]bool{root: true}
+	if policy == metav1.DeletePropagationOrphan {
+		return removed
+	}
+
+	candidates := objs.descendants(root)
+	for changed := true; changed; {
+		changed = false
+		for uid := range candidates {
+			if removed[uid] {
+				continue
+			}
+			o := objs.items[uid]
+			allGone := true
+			for _, ref := range o.GetOwnerReferences() {
+				if !removed[ref.UID] {
+					allGone = false
+					break
+				}
+			}
+			if allGone {
+				removed[uid] = true
+				changed = true
+			}
+		}
+	}
+	return removed
+}
+
+// findTreeObject returns the object of the tree whose Kind/name is name. The kind is
+// matched case-insensitively.
+func findTreeObject(objs objectDirectory, uids map[types.UID]bool, name string) (unstructured.Unstructured, error) {
+	kind, n, _ := strings.Cut(name, "/")
+	for uid := range uids {
+		if o, ok := objs.items[uid]; ok && strings.EqualFold(o.GetKind(), kind) && o.GetName() == n {
+			return o, nil
+		}
+	}
+	return unstructured.Unstructured{}, fmt.Errorf("%s is not part of the tree", name)
+}
+
+// printDeleteSimulation prints every ownership edge in the tree with its controller and
+// blockOwnerDeletion flags, and what deleting root with policy would do to each object.
+func printDeleteSimulation(root unstructured.Unstructured, objs objectDirectory, policy metav1.DeletionPropagation) error {
+	removed := simulateDeletion(root.GetUID(), objs, policy)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tOBJECT\tOWNER\tCONTROLLER\tBLOCK-OWNER-DELETION")
+	fmt.Fprintf(w, "delete\t%s\t-\t-\t-\n", displayName(root))
+
+	var blocking int
+	seen := map[types.UID]bool{root.GetUID(): true}
+	queue := []types.UID{root.GetUID()}
+	for len(queue) > 0 {
+		owner := queue[0]
+		queue = queue[1:]
+		for child := range objs.ownership[owner] {
+			o := objs.items[child]
+			for _, ref := range o.GetOwnerReferences() {
+				if ref.UID != owner {
+					continue
+				}
+				action := "keep"
+				switch {
+				case removed[child]:
+					action = "delete"
+				case owner == root.GetUID() && policy == metav1.DeletePropagationOrphan:
+					action = "orphan"
+				}
+				block := ref.BlockOwnerDeletion != nil && *ref.BlockOwnerDeletion
+				if block && removed[child] {
+					blocking++
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s/%s\t%t\t%t\n", action, displayName(o), ref.Kind, ref.Name,
+					ref.Controller != nil && *ref.Controller, block)
+			}
+			if !seen[child] {
+				seen[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s deletion removes %d object(s).\n", policy, len(removed))
+	if policy == metav1.DeletePropagationForeground && blocking > 0 {
+		fmt.Printf("%s stays until %d dependent(s) with blockOwnerDeletion are deleted.\n", displayName(root), blocking)
+	}
+	return nil
+}
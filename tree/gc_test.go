@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// testObject returns an object named uid, owned by owners.
+func testObject(uid string, owners ...string) unstructured.Unstructured {
+	var o unstructured.Unstructured
+	o.SetAPIVersion("v1")
+	o.SetKind("ConfigMap")
+	o.SetName(uid)
+	o.SetUID(types.UID(uid))
+	var refs []metav1.OwnerReference
+	for _, owner := range owners {
+		refs = append(refs, metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: owner, UID: types.UID(owner)})
+	}
+	o.SetOwnerReferences(refs)
+	return o
+}
+
+func TestSimulateDeletion(t *testing.T) {
+	objs := newObjectDirectory([]unstructured.Unstructured{
+		testObject("root"),
+		testObject("child", "root"),
+		testObject("grandchild", "child"),
+		// owned by two members of the tree, goes once both are gone
+		testObject("shared-in-tree", "root", "child"),
+		// also owned by an object outside of the tree, which stays
+		testObject("other"),
+		testObject("shared-outside", "root", "other"),
+		testObject("under-shared-outside", "shared-outside"),
+		// also owned by an object that is not listed, e.g. cluster-scoped
+		testObject("unlisted-owner", "root", "cluster-scoped"),
+	})
+
+	removedByPropagation := []string{"root", "child", "grandchild", "shared-in-tree"}
+	tests := []struct {
+		policy metav1.DeletionPropagation
+		want   []string
+	}{
+		{metav1.DeletePropagationBackground, removedByPropagation},
+		{metav1.DeletePropagationForeground, removedByPropagation},
+		{metav1.DeletePropagationOrphan, []string{"root"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			got := simulateDeletion("root", objs, tt.policy)
+			if len(got) != len(tt.want) {
+				t.Errorf("removed %d objects, want %d: %v", len(got), len(tt.want), got)
+			}
+			for _, uid := range tt.want {
+				if !got[types.UID(uid)] {
+					t.Errorf("%s was not removed", uid)
+				}
+			}
+		})
+	}
+}
+
+func TestSimulateDeletionOfSubtree(t *testing.T) {
+	objs := newObjectDirectory([]unstructured.Unstructured{
+		testObject("root"),
+		testObject("child", "root"),
+		testObject("sibling", "root"),
+		testObject("grandchild", "child"),
+	})
+	got := simulateDeletion("child", objs, metav1.DeletePropagationBackground)
+	want := map[types.UID]bool{"child": true, "grandchild": true}
+	if len(got) != len(want) {
+		t.Fatalf("removed %v, want %v", got, want)
+	}
+	for uid := range want {
+		if !got[uid] {
+			t.Errorf("%s was not removed", uid)
+		}
+	}
+}
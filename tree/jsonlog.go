@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// fieldMatch is a --field condition: the JSON field at path must equal value.
+type fieldMatch struct {
+	path  []string
+	value string
+}
+
+// fieldList is a repeatable flag of key=value field conditions. Keys may use dots
+// to reach into nested objects, e.g. http.status=500.
+type fieldList []fieldMatch
+
+func (l *fieldList) String() string {
+	var s []string
+	for _, f := range *l {
+		s = append(s, strings.Join(f.path, ".")+"="+f.value)
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *fieldList) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i <= 0 {
+		return fmt.Errorf("%q is not in key=value form", v)
+	}
+	*l = append(*l, fieldMatch{path: strings.Split(v[:i], "."), value: v[i+1:]})
+	return nil
+}
+
+// pathList is a repeatable flag of JSON field paths, with dots for nested keys.
+type pathList [][]string
+
+func (l *pathList) String() string {
+	var s []string
+	for _, p := range *l {
+		s = append(s, strings.Join(p, "."))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *pathList) Set(v string) error {
+	if v == "" {
+		return fmt.Errorf("empty field path")
+	}
+	*l = append(*l, strings.Split(v, "."))
+	return nil
+}
+
+// parseJSONLine returns the fields of s if it is a JSON object.
+func parseJSONLine(s string) (map[string]interface{}, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") {
+		return nil, false
+	}
+	// numbers are kept as json.Number so large IDs are not turned into floats
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+	return m, true
+}
+
+// redactFields replaces the values at paths in the JSON object s with "[REDACTED]".
+// Lines that are not JSON objects, or have none of the fields, are returned as they
+// are; others are encoded again, with their keys sorted.
+func redactFields(s string, paths [][]string) string {
+	fields, ok := parseJSONLine(s)
+	if !ok {
+		return s
+	}
+	changed := false
+	for _, path := range paths {
+		m := fields
+		for _, p := range path[:len(path)-1] {
+			if m, ok = m[p].(map[string]interface{}); !ok {
+				break
+			}
+		}
+		last := path[len(path)-1]
+		if _, found := m[last]; ok && found {
+			m[last] = redacted
+			changed = true
+		}
+	}
+	if !changed {
+		return s
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fields); err != nil {
+		return s
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// lookupField returns the value at the dotted path in fields.
+func lookupField(fields map[string]interface{}, path []string) (interface{}, bool) {
+	var cur interface{} = fields
+	for _, p := range path {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[p]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// matchFields reports whether fields satisfies all of the --field conditions of opts.
+// Lines that are not JSON never match when conditions are given.
+func (opts logOptions) matchFields(fields map[string]interface{}) bool {
+	for _, f := range opts.fields {
+		v, ok := lookupField(fields, f.path)
+		if !ok || fieldString(v) != f.value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLine reports whether l carries the correlation ID of a --follow-id condition:
+// the JSON field at f.path equals f.value, or for other lines, such as ones with the
+// ID in a logged header, the text contains f.value.
+func (f fieldMatch) matchLine(l logLine) bool {
+	if l.fields != nil {
+		if v, ok := lookupField(l.fields, f.path); ok {
+			return fieldString(v) == f.value
+		}
+	}
+	return strings.Contains(l.text, f.value)
+}
+
+// fieldString formats a JSON value for comparison and display.
+func fieldString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// flattenFields renders the selected fields as space separated key=value pairs,
+// quoting values that contain spaces. Missing fields are left out.
+func flattenFields(fields map[string]interface{}, keys []string) string {
+	var out []string
+	for _, k := range keys {
+		v, ok := lookupField(fields, strings.Split(k, "."))
+		if !ok {
+			continue
+		}
+		s := fieldString(v)
+		if strings.ContainsAny(s, " \t\"=") {
+			s = strconv.Quote(s)
+		}
+		out = append(out, k+"="+s)
+	}
+	return strings.Join(out, " ")
+}
+
+// prettyJSON indents the JSON object in s.
+func prettyJSON(s string) string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(strings.TrimSpace(s)), "", "  "); err != nil {
+		return s
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestParseJSONLineKeepsNumbers(t *testing.T) {
+	tests := []struct {
+		line  string
+		path  []string
+		want  string
+		isObj bool
+	}{
+		{`{"id":1000000}`, []string{"id"}, "1000000", true},
+		{`{"id":12345678901}`, []string{"id"}, "12345678901", true},
+		{`{"latency":0.25}`, []string{"latency"}, "0.25", true},
+		{`{"http":{"status":500}}`, []string{"http", "status"}, "500", true},
+		{`  {"msg":"ok"}  `, []string{"msg"}, "ok", true},
+		{`{"msg":"ok"} trailing`, nil, "", false},
+		{`plain text`, nil, "", false},
+		{`[1,2]`, nil, "", false},
+	}
+	for _, tt := range tests {
+		fields, ok := parseJSONLine(tt.line)
+		if ok != tt.isObj {
+			t.Errorf("parseJSONLine(%q) ok = %v, want %v", tt.line, ok, tt.isObj)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		v, found := lookupField(fields, tt.path)
+		if !found {
+			t.Errorf("parseJSONLine(%q): field %v not found", tt.line, tt.path)
+			continue
+		}
+		if got := fieldString(v); got != tt.want {
+			t.Errorf("parseJSONLine(%q): field %v = %q, want %q", tt.line, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	paths := [][]string{{"password"}, {"user", "email"}}
+	tests := []struct {
+		line string
+		want string
+	}{
+		{`{"msg":"login","password":"hunter2"}`, `{"msg":"login","password":"[REDACTED]"}`},
+		{`{"user":{"email":"a@b.c","id":12345678901},"msg":"<ok>"}`, `{"msg":"<ok>","user":{"email":"[REDACTED]","id":12345678901}}`},
+		// unchanged lines keep their formatting
+		{`{"msg": "no secrets"}`, `{"msg": "no secrets"}`},
+		{`{"user":"bob"}`, `{"user":"bob"}`},
+		{`password=hunter2`, `password=hunter2`},
+	}
+	for _, tt := range tests {
+		if got := redactFields(tt.line, paths); got != tt.want {
+			t.Errorf("redactFields(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// logOptions controls which logs are requested from each container.
+type logOptions struct {
+	follow         bool
+	previous       bool           // logs of the previous instance of each container
+	container      *regexp.Regexp // only stream containers whose name matches, if set
+	initContainers bool
+	allContainers  bool               // init and ephemeral containers too
+	since          time.Duration      // only logs newer than this, if set
+	sinceTime      *metav1.Time       // only logs after this time, if set
+	tail           int64              // number of lines from the end of each log, -1 for all
+	redact         []redaction        // mask matches of these in every line
+	redactFields   [][]string         // mask the values of these fields in JSON lines
+	include        []*regexp.Regexp   // only lines matching any of these, if set
+	exclude        []*regexp.Regexp   // drop lines matching any of these
+	parseJSON      bool               // parse lines that are JSON objects into fields
+	fields         fieldList          // only JSON lines with these field values, if set
+	timestamps     bool               // request and parse the timestamp of each line
+	ordered        bool               // merge streams by timestamp instead of arrival order
+	orderWindow    time.Duration      // how long lines are buffered for ordering
+	dedupe         bool               // collapse identical consecutive lines of a container
+	podStates      podStateFilter     // which pods are streamed
+	followID       *fieldMatch        // only lines carrying this correlation ID, if set
+	controllers    map[types.UID]bool // pods whose lines are labelled as controller logs
+}
+
+// apiTimestamps reports whether the API server is asked to prefix lines with their
+// timestamp. Followed streams always need them to resume after reconnecting.
+func (opts logOptions) apiTimestamps() bool {
+	return opts.timestamps || opts.ordered || opts.follow
+}
+
+// podLogOptions returns the log request options for container.
+func (opts logOptions) podLogOptions(container string) *corev1.PodLogOptions {
+	out := &corev1.PodLogOptions{
+		Container:  container,
+		Follow:     opts.follow,
+		Previous:   opts.previous,
+		Timestamps: opts.apiTimestamps(),
+		SinceTime:  opts.sinceTime,
+	}
+	if opts.since > 0 {
+		sec := int64(opts.since.Round(time.Second).Seconds())
+		out.SinceSeconds = &sec
+	}
+	if opts.tail >= 0 {
+		out.TailLines = &opts.tail
+	}
+	return out
+}
+
+// containers returns the names of the containers of pod selected by opts.
+func (opts logOptions) containers(pod *corev1.Pod) []string {
+	var out []string
+	if opts.initContainers || opts.allContainers {
+		for _, c := range pod.Spec.InitContainers {
+			out = append(out, c.Name)
+		}
+	}
+	for _, c := range pod.Spec.Containers {
+		out = append(out, c.Name)
+	}
+	if opts.allContainers {
+		for _, c := range pod.Spec.EphemeralContainers {
+			out = append(out, c.Name)
+		}
+	}
+	return opts.filter(out)
+}
+
+// startedContainers returns the names of the containers of pod selected by opts that
+// are running or have terminated, and therefore have logs.
+func (opts logOptions) startedContainers(pod *corev1.Pod) []string {
+	selected := make(map[string]bool)
+	for _, c := range opts.containers(pod) {
+		selected[c] = true
+	}
+	var out []string
+	for _, cs := range containerStatuses(pod) {
+		if selected[cs.Name] && (cs.State.Running != nil || cs.State.Terminated != nil) {
+			out = append(out, cs.Name)
+		}
+	}
+	return out
+}
+
+func (opts logOptions) filter(names []string) []string {
+	if opts.container == nil {
+		return names
+	}
+	var out []string
+	for _, n := range names {
+		if opts.container.MatchString(n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// logLine is a single line read from a container's log stream.
+type logLine struct {
+	pod        *corev1.Pod
+	container  string
+	text       string
+	fields     map[string]interface{} // when the line is a JSON object and parsing is enabled
+	timestamp  time.Time              // as reported by the API server, when requested
+	received   time.Time              // when the line entered the ordering buffer
+	repeats    int                    // how many identical consecutive lines this stands for, with dedupe
+	controller bool                   // read from the controller managing the root
+}
+
+// podStateFilter selects pods by phase.
+type podStateFilter struct {
+	excludeCompleted bool // drop Succeeded and Evicted pods
+	onlyRunning      bool // drop pods that are not Running
+}
+
+// keep reports whether a pod with phase and status reason passes f.
+func (f podStateFilter) keep(phase corev1.PodPhase, reason string) bool {
+	if f.onlyRunning && phase != corev1.PodRunning {
+		return false
+	}
+	if f.excludeCompleted && (phase == corev1.PodSucceeded || (phase == corev1.PodFailed && reason == "Evicted")) {
+		return false
+	}
+	return true
+}
+
+// keepObject reports whether o passes f. Objects other than pods always do.
+func (f podStateFilter) keepObject(o unstructured.Unstructured) bool {
+	if o.GetKind() != "Pod" || o.GetAPIVersion() != "v1" {
+		return true
+	}
+	phase, _, _ := unstructured.NestedString(o.Object, "status", "phase")
+	reason, _, _ := unstructured.NestedString(o.Object, "status", "reason")
+	return f.keep(corev1.PodPhase(phase), reason)
+}
+
+// filterPods returns the pods that pass f.
+func (f podStateFilter) filterPods(pods []corev1.Pod) []corev1.Pod {
+	var out []corev1.Pod
+	for _, p := range pods {
+		if f.keep(p.Status.Phase, p.Status.Reason) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// treePods returns the pods among the objects in uids, ordered by name.
+func treePods(objs objectDirectory, uids map[types.UID]bool) ([]corev1.Pod, error) {
+	var out []corev1.Pod
+	for uid := range uids {
+		o, ok := objs.items[uid]
+		if !ok || o.GetKind() != "Pod" || o.GetAPIVersion() != "v1" {
+			continue
+		}
+		var pod corev1.Pod
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, &pod); err != nil {
+			return nil, fmt.Errorf("failed to convert pod %s: %w", o.GetName(), err)
+		}
+		out = append(out, pod)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// logStreamer fans the log streams of individual containers into a single channel.
+type logStreamer struct {
+	client corev1client.PodsGetter
+	opts   logOptions
+	lines  chan logLine
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	started map[string]bool                    // by pod UID, container and restart count
+	cancel  map[types.UID][]context.CancelFunc // open streams by pod UID
+	failed  []error                            // streams that gave up
+}
+
+func newLogStreamer(client corev1client.PodsGetter, opts logOptions) *logStreamer {
+	return &logStreamer{
+		client:  client,
+		opts:    opts,
+		lines:   make(chan logLine),
+		started: make(map[string]bool),
+		cancel:  make(map[types.UID][]context.CancelFunc),
+	}
+}
+
+// start opens a stream for the current instance of container in pod, unless one was
+// already opened for it. A stream that fails is reported on stderr and kept in s.failed.
+func (s *logStreamer) start(pod *corev1.Pod, container string) {
+	key := fmt.Sprintf("%s/%s/%d", pod.UID, container, restartCount(pod, container))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started[key] {
+		return
+	}
+	s.started[key] = true
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel[pod.UID] = append(s.cancel[pod.UID], cancel)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.streamWithRetry(ctx, pod, container); err != nil {
+			s.mu.Lock()
+			s.failed = append(s.failed, err)
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// maxReconnects is how often in a row a followed stream that failed without delivering
+// a line is reopened before giving up.
+const maxReconnects = 5
+
+// streamWithRetry streams container of pod. When following, a stream that fails is
+// reopened with exponential backoff, resuming after the last line that was sent. The
+// backoff starts over once a reopened stream delivers a line. It returns the error of
+// the last attempt if the stream gave up.
+func (s *logStreamer) streamWithRetry(ctx context.Context, pod *corev1.Pod, container string) error {
+	var last time.Time
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		prev := last
+		var err error
+		last, err = s.stream(ctx, pod, container, last)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		if last.After(prev) {
+			// the stream delivered lines before it dropped, start counting failures over
+			attempt, backoff = 0, time.Second
+		}
+		if !s.opts.follow || attempt == maxReconnects {
+			fmt.Fprintf(os.Stderr, "failed to stream logs of %s/%s: %v\n", pod.Name, container, err)
+			return fmt.Errorf("failed to stream logs of %s/%s: %w", pod.Name, container, err)
+		}
+		fmt.Fprintf(os.Stderr, "log stream of %s/%s dropped (%v), reconnecting in %s\n", pod.Name, container, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// stop closes all open streams of the pod with uid.
+func (s *logStreamer) stop(uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.cancel[uid] {
+		cancel()
+	}
+	delete(s.cancel, uid)
+}
+
+// stream sends the log lines of a single container to s.lines until the stream ends.
+// If resume is set, only lines after it are sent. It returns the timestamp of the last
+// line read, or resume if there was none.
+func (s *logStreamer) stream(ctx context.Context, pod *corev1.Pod, container string, resume time.Time) (time.Time, error) {
+	opts := s.opts.podLogOptions(container)
+	if !resume.IsZero() {
+		// sinceTime has a resolution of seconds, lines up to resume are skipped below
+		t := metav1.NewTime(resume)
+		opts.SinceTime, opts.SinceSeconds, opts.TailLines = &t, nil, nil
+	}
+	rc, err := s.client.Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+	if err != nil {
+		return resume, err
+	}
+	defer rc.Close()
+
+	last := resume
+	r := bufio.NewReader(rc)
+	for {
+		text, err := r.ReadString('\n')
+		if text != "" {
+			l := s.newLine(pod, container, strings.TrimSuffix(text, "\n"))
+			if resume.IsZero() || l.timestamp.After(resume) {
+				if !l.timestamp.IsZero() {
+					last = l.timestamp
+				}
+				if !s.emit(ctx, l) {
+					return last, nil
+				}
+			}
+		}
+		if err == io.EOF {
+			return last, nil
+		} else if err != nil {
+			return last, err
+		}
+	}
+}
+
+// newLine returns the log line for text, with its timestamp split off if timestamps
+// were requested.
+func (s *logStreamer) newLine(pod *corev1.Pod, container, text string) logLine {
+	l := logLine{pod: pod, container: container, text: text, controller: s.opts.controllers[pod.UID]}
+	if s.opts.apiTimestamps() {
+		l.timestamp, l.text = splitTimestamp(l.text)
+	}
+	return l
+}
+
+// emit processes a log line and sends it to s.lines unless it is filtered out.
+// It returns false if ctx was cancelled before the line could be sent.
+func (s *logStreamer) emit(ctx context.Context, l logLine) bool {
+	l.text = redact(l.text, s.opts.redact)
+	if len(s.opts.redactFields) > 0 {
+		l.text = redactFields(l.text, s.opts.redactFields)
+	}
+	if !s.opts.keep(l.text) {
+		return true
+	}
+	if s.opts.parseJSON {
+		l.fields, _ = parseJSONLine(l.text)
+		if !s.opts.matchFields(l.fields) {
+			return true
+		}
+	}
+	if s.opts.followID != nil && !s.opts.followID.matchLine(l) {
+		return true
+	}
+	select {
+	case s.lines <- l:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// write copies lines to w until the lines channel is closed.
+func (s *logStreamer) write(w lineWriter) error {
+	lines := s.lines
+	if s.opts.ordered {
+		ordered := make(chan logLine)
+		go orderLines(s.lines, ordered, s.opts.orderWindow)
+		lines = ordered
+	}
+	if s.opts.dedupe {
+		return newDeduper(w).run(lines)
+	}
+	for l := range lines {
+		if err := w.write(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineWriter receives the lines of a logStreamer.
+type lineWriter interface {
+	write(l logLine) error
+}
+
+// streamLogs writes the logs of every container of pods to w and returns once all
+// streams have ended. It returns an error if any of the streams failed.
+func streamLogs(client corev1client.PodsGetter, pods []corev1.Pod, opts logOptions, w lineWriter) error {
+	s := newLogStreamer(client, opts)
+	n := 0
+	for i := range pods {
+		for _, c := range opts.containers(&pods[i]) {
+			if opts.previous && !hasPreviousInstance(&pods[i], c) {
+				continue
+			}
+			s.start(&pods[i], c)
+			n++
+		}
+	}
+	go func() {
+		s.wg.Wait()
+		close(s.lines)
+	}()
+	if err := s.write(w); err != nil {
+		return err
+	}
+	switch len(s.failed) {
+	case 0:
+		return nil
+	case 1:
+		return s.failed[0]
+	default:
+		return fmt.Errorf("%d of %d log streams failed, first: %w", len(s.failed), n, s.failed[0])
+	}
+}
+
+// containerStatuses returns the statuses of all init, regular and ephemeral containers of pod.
+func containerStatuses(pod *corev1.Pod) []corev1.ContainerStatus {
+	var out []corev1.ContainerStatus
+	out = append(out, pod.Status.InitContainerStatuses...)
+	out = append(out, pod.Status.ContainerStatuses...)
+	out = append(out, pod.Status.EphemeralContainerStatuses...)
+	return out
+}
+
+// hasPreviousInstance reports whether container in pod has a terminated previous instance.
+func hasPreviousInstance(pod *corev1.Pod, container string) bool {
+	for _, cs := range containerStatuses(pod) {
+		if cs.Name == container {
+			return cs.LastTerminationState.Terminated != nil
+		}
+	}
+	return false
+}
+
+// splitTimestamp splits the RFC3339 timestamp the API server prepends to log lines
+// when timestamps are requested from the rest of the line.
+func splitTimestamp(s string) (time.Time, string) {
+	i := strings.IndexByte(s, ' ')
+	if i < 0 {
+		i = len(s)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s[:i])
+	if err != nil {
+		return time.Time{}, s
+	}
+	if i < len(s) {
+		i++
+	}
+	return t, s[i:]
+}
+
+// restartCount returns the restart count of container in pod's status, or 0 if unknown.
+func restartCount(pod *corev1.Pod, container string) int32 {
+	for _, cs := range containerStatuses(pod) {
+		if cs.Name == container {
+			return cs.RestartCount
+		}
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSplitTimestamp(t *testing.T) {
+	ts := time.Date(2022, 6, 1, 12, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		s        string
+		wantTime time.Time
+		wantRest string
+	}{
+		{"2022-06-01T12:30:00.123456789Z hello world", ts, "hello world"},
+		{"2022-06-01T12:30:00.123456789Z ", ts, ""},
+		{"2022-06-01T12:30:00.123456789Z", ts, ""},
+		{"hello world", time.Time{}, "hello world"},
+		{"", time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		gotTime, gotRest := splitTimestamp(tt.s)
+		if !gotTime.Equal(tt.wantTime) || gotRest != tt.wantRest {
+			t.Errorf("splitTimestamp(%q) = %v, %q, want %v, %q", tt.s, gotTime, gotRest, tt.wantTime, tt.wantRest)
+		}
+	}
+}
+
+func TestPodLogOptions(t *testing.T) {
+	since := metav1.NewTime(time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		desc           string
+		opts           logOptions
+		wantTimestamps bool
+		wantSince      int64 // 0 if unset
+		wantTail       int64 // -1 if unset
+	}{
+		{"defaults", logOptions{tail: -1}, false, 0, -1},
+		{"tail", logOptions{tail: 10}, false, 0, 10},
+		{"since rounded to seconds", logOptions{tail: -1, since: 1500 * time.Millisecond}, false, 2, -1},
+		{"timestamps", logOptions{tail: -1, timestamps: true}, true, 0, -1},
+		{"ordered needs timestamps", logOptions{tail: -1, ordered: true}, true, 0, -1},
+		{"follow needs timestamps to resume", logOptions{tail: -1, follow: true}, true, 0, -1},
+		{"since time", logOptions{tail: -1, sinceTime: &since}, false, 0, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := tt.opts.podLogOptions("app")
+			if got.Container != "app" || got.Follow != tt.opts.follow || got.SinceTime != tt.opts.sinceTime {
+				t.Errorf("podLogOptions() = %+v, does not match %+v", got, tt.opts)
+			}
+			if got.Timestamps != tt.wantTimestamps {
+				t.Errorf("Timestamps = %v, want %v", got.Timestamps, tt.wantTimestamps)
+			}
+			if (got.SinceSeconds == nil) != (tt.wantSince == 0) || got.SinceSeconds != nil && *got.SinceSeconds != tt.wantSince {
+				t.Errorf("SinceSeconds = %v, want %d", got.SinceSeconds, tt.wantSince)
+			}
+			if (got.TailLines == nil) != (tt.wantTail < 0) || got.TailLines != nil && *got.TailLines != tt.wantTail {
+				t.Errorf("TailLines = %v, want %d", got.TailLines, tt.wantTail)
+			}
+		})
+	}
+}
+
+func TestPodStateFilter(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase, reason string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: corev1.PodStatus{Phase: phase, Reason: reason}}
+	}
+	pods := []corev1.Pod{
+		pod("running", corev1.PodRunning, ""),
+		pod("pending", corev1.PodPending, ""),
+		pod("succeeded", corev1.PodSucceeded, ""),
+		pod("evicted", corev1.PodFailed, "Evicted"),
+		pod("failed", corev1.PodFailed, "Error"),
+	}
+	tests := []struct {
+		desc string
+		f    podStateFilter
+		want []string
+	}{
+		{"no filter", podStateFilter{}, []string{"running", "pending", "succeeded", "evicted", "failed"}},
+		{"exclude completed", podStateFilter{excludeCompleted: true}, []string{"running", "pending", "failed"}},
+		{"only running", podStateFilter{onlyRunning: true}, []string{"running"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var got []string
+			for _, p := range tt.f.filterPods(pods) {
+				got = append(got, p.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterPods() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTreeLeavesOutFilteredPods(t *testing.T) {
+	running, done := testObject("web-1", "rs"), testObject("web-0", "rs")
+	for _, p := range []struct {
+		o     *unstructured.Unstructured
+		phase string
+	}{{&running, "Running"}, {&done, "Succeeded"}} {
+		p.o.SetKind("Pod")
+		_ = unstructured.SetNestedField(p.o.Object, p.phase, "status", "phase")
+	}
+	rs := testObject("rs", "root")
+	rs.SetKind("ReplicaSet")
+	// the pod filter applies to pods only
+	job := testObject("job", "root")
+	job.SetKind("Job")
+	_ = unstructured.SetNestedField(job.Object, "Succeeded", "status", "phase")
+	root := testObject("root")
+	objs := newObjectDirectory([]unstructured.Unstructured{root, rs, running, done, job})
+
+	got := renderTree(root, objs, podStateFilter{excludeCompleted: true}.keepObject, false)
+	want := "ConfigMap/root\n  Job/job\n  ReplicaSet/rs\n    Pod/web-1\n"
+	if got != want {
+		t.Errorf("renderTree() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -4,93 +4,296 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // combined authprovider import
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 )
 
-func run(kind string, name string, ns string) error {
+func run(args []string) error {
+	if len(args) > 0 {
+		for _, cmd := range commands {
+			if cmd.name == args[0] {
+				return cmd.run(args[1:])
+			}
+		}
+	}
+	return runLogs(args)
+}
 
-	var kubeconfig *string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+// runLogs streams the logs of the pods of the tree, the default command.
+func runLogs(args []string) error {
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	tf := addTreeFlags(fs)
+	follow := fs.Bool("follow", false, "keep streaming logs as they are written, including from pods created later")
+	fs.BoolVar(follow, "f", false, "shorthand for --follow")
+	container := fs.String("container", "", "only stream containers whose name matches this regular expression")
+	fs.StringVar(container, "c", "", "shorthand for --container")
+	initContainers := fs.Bool("init-containers", false, "also stream init containers")
+	allContainers := fs.Bool("all-containers", false, "also stream init and ephemeral containers")
+	podStates := addPodStateFlags(fs)
+	previous := fs.Bool("previous", false, "show logs of the previous instance of each container that has restarted")
+	fs.BoolVar(previous, "p", false, "shorthand for --previous")
+	since := fs.Duration("since", 0, "only show logs newer than a relative duration like 10m or 1h")
+	sinceTime := fs.String("since-time", "", "only show logs after this RFC3339 timestamp")
+	tail := fs.Int64("tail", -1, "number of lines to show from the end of each container's log, -1 for all")
+	ordered := fs.Bool("ordered", false, "merge the streams of all containers in timestamp order instead of arrival order")
+	orderWindow := fs.Duration("order-window", 2*time.Second, "how long lines are buffered to be put in order, used with --ordered")
+	timestamps := fs.Bool("timestamps", false, "show the timestamp of each line")
+	output := fs.String("output", "text", "log output format: text, json (one NDJSON record per line) or logfmt")
+	fs.StringVar(output, "o", "text", "shorthand for --output")
+	outputDir := fs.String("output-dir", "", "write each container's logs to namespace_pod_container.log in this directory instead of stdout")
+	archive := fs.String("archive", "", "write the tree and the logs of all its pods to this .tar.gz file")
+	stats := fs.Bool("stats", false, "print the line rate of every pod/container instead of their logs")
+	statsInterval := fs.Duration("stats-interval", 10*time.Second, "how often --stats prints a summary")
+	var followID fieldList
+	fs.Var(&followID, "follow-id", "only show lines carrying this correlation ID, as FIELD=VALUE, across all pods in timestamp order; lines without FIELD match if they contain VALUE (implies --parse-json and --ordered)")
+	traceLink := fs.String("trace-link", "", "Go template for a link to the trace of JSON lines with a trace ID, e.g. 'https://tempo.example.com/trace/{{.TraceID}}'; also has .SpanID, .Namespace and .PodName (implies --parse-json)")
+	dedupe := fs.Bool("dedupe", false, "collapse identical consecutive lines of a container into one with a repeat count; a line is shown once a different one follows or it has not repeated for a second")
+	tmpl := fs.String("template", "", "Go template for each log line, e.g. '{{.PodName}} {{.ContainerName}} {{.Message}}'; also has .Namespace, .Labels, .Timestamp, .Fields, .TraceLink and .Cluster")
+	noColor := fs.Bool("no-color", false, "do not color the pod/container prefixes and highlighted matches of log lines")
+	var include, exclude regexpList
+	fs.Var(&include, "include", "only show log lines matching this regular expression (repeatable, any may match)")
+	fs.Var(&exclude, "exclude", "hide log lines matching this regular expression (repeatable)")
+	var highlights regexpList
+	fs.Var(&highlights, "highlight", "color matches of this regular expression in log lines, in addition to --include matches (repeatable)")
+	parseJSON := fs.Bool("parse-json", false, "parse log lines that are JSON objects, implied by --field, --select, --pretty, --trace-link and --follow-id")
+	var fields fieldList
+	fs.Var(&fields, "field", "only show JSON log lines whose field has this value, as key=value with dots for nested keys (repeatable)")
+	selectFields := fs.String("select", "", "comma separated JSON fields to show, flattened as key=value, instead of the whole line")
+	pretty := fs.Bool("pretty", false, "indent JSON log lines")
+	var redactions redactList
+	fs.Var(&redactions, "redact", "mask matches of this regular expression in log lines, or one of the presets: bearer, email, card (repeatable)")
+	controllerLogs := fs.Bool("controller-logs", false, "also stream the logs of the controller managing the root, found by the field managers of its status or --controller, labelled [controller]")
+	controllers := controllerMap{}
+	fs.Var(controllers, "controller", "the Deployment of the controller of a kind for --controller-logs, as KIND=NAMESPACE/NAME (repeatable)")
+	var redactedFields pathList
+	fs.Var(&redactedFields, "redact-field", "mask the value of this field of JSON log lines, with dots for nested keys (repeatable)")
+	var sinks sinkList
+	fs.Var(&sinks, "sink", "also push log lines to loki=URL (a Loki push endpoint) or webhook=URL (NDJSON records as with --output json), in batches sent at least every second (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: tlogs [flags] KIND NAME\n       tlogs COMMAND [flags] KIND NAME [ARGS]\n\nCommands:\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(fs.Output(), "  %-16s %s\n", cmd.name, cmd.help)
+		}
+		fmt.Fprintf(fs.Output(), "\nWithout a command, tlogs streams the logs of the tree's pods.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	pos := parseArgs(fs, args)
+	if len(pos) != 2 {
+		return fmt.Errorf("usage: tlogs [flags] KIND NAME, or tlogs -h for commands")
 	}
-	flag.Parse()
+	kind, name := pos[0], pos[1]
 
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
-	config.QPS = 1000
-	config.Burst = 1000
-	if err != nil {
-		panic(err)
+	if *output != "text" && *output != "json" && *output != "logfmt" {
+		return fmt.Errorf("unknown --output %q, use one of: text, json, logfmt", *output)
 	}
-	dyn, err := dynamic.NewForConfig(config)
-	if err != nil {
-		panic(err)
+	if *archive != "" && *stats {
+		return fmt.Errorf("only one of --archive and --stats can be used")
+	}
+	if *archive != "" && *outputDir != "" {
+		return fmt.Errorf("only one of --archive and --output-dir can be used")
+	}
+	if len(sinks) > 0 && (*archive != "" || *stats) {
+		return fmt.Errorf("--sink cannot be used with --archive or --stats")
+	}
+	if *stats && *outputDir != "" {
+		return fmt.Errorf("--stats cannot be used with --output-dir")
+	}
+	if *stats && *statsInterval <= 0 {
+		return fmt.Errorf("--stats-interval must be positive")
+	}
+	if *tmpl != "" && *output != "text" {
+		return fmt.Errorf("--template can only be used with --output text")
+	}
+	if len(followID) > 1 {
+		return fmt.Errorf("--follow-id can only be given once")
+	}
+	if len(followID) == 1 {
+		*ordered = true
+		*parseJSON = true
+	}
+	if *ordered && *orderWindow <= 0 {
+		return fmt.Errorf("--order-window must be positive")
+	}
+	if *archive != "" && *follow {
+		return fmt.Errorf("--archive cannot be used with --follow")
+	}
+	if *previous && *follow {
+		return fmt.Errorf("--previous cannot be used with --follow")
+	}
+
+	var sinceT *metav1.Time
+	if *sinceTime != "" {
+		if *since != 0 {
+			return fmt.Errorf("only one of --since and --since-time can be used")
+		}
+		t, err := time.Parse(time.RFC3339, *sinceTime)
+		if err != nil {
+			return fmt.Errorf("invalid --since-time: %w", err)
+		}
+		sinceT = &metav1.Time{Time: t}
 	}
 
-	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	containerRE, err := containerRegexp(*container)
 	if err != nil {
 		return err
 	}
 
-	apis, err := findAPIs(dc)
+	c, t, err := tf.load(kind, name)
 	if err != nil {
 		return err
 	}
-
-	var api apiResource
-	if k, ok := overrideType(kind, apis); ok {
-		api = k
-	} else {
-		apiResults := apis.lookup(kind)
-		if len(apiResults) == 0 {
-			return fmt.Errorf("could not find api kind %q", kind)
-		} else if len(apiResults) > 1 {
-			names := make([]string, 0, len(apiResults))
-			for _, a := range apiResults {
-				names = append(names, fullAPIName(a))
-			}
-			return fmt.Errorf("ambiguous kind %q. use one of these as the KIND disambiguate: [%s]", kind,
-				strings.Join(names, ", "))
+	cs, err := c.coreClient()
+	if err != nil {
+		return err
+	}
+	opts := logOptions{
+		follow:         *follow,
+		previous:       *previous,
+		container:      containerRE,
+		initContainers: *initContainers,
+		allContainers:  *allContainers,
+		since:          *since,
+		sinceTime:      sinceT,
+		tail:           *tail,
+		redact:         redactions,
+		redactFields:   redactedFields,
+		include:        include,
+		exclude:        exclude,
+		parseJSON:      *parseJSON || len(fields) > 0 || *selectFields != "" || *pretty || *traceLink != "",
+		fields:         fields,
+		timestamps:     *timestamps || *output != "text" || *tmpl != "" || len(sinks) > 0,
+		ordered:        *ordered,
+		orderWindow:    *orderWindow,
+		dedupe:         *dedupe,
+		podStates:      *podStates,
+	}
+	if len(followID) == 1 {
+		opts.followID = &followID[0]
+	}
+	var ctrlPods []corev1.Pod
+	if *controllerLogs {
+		if ctrlPods, err = controllerPods(c.dyn, cs, t.root, controllers); err != nil {
+			return err
+		}
+		opts.controllers = make(map[types.UID]bool)
+		for _, p := range ctrlPods {
+			opts.controllers[p.UID] = true
 		}
-		api = apiResults[0]
 	}
-
-	var ri dynamic.ResourceInterface
-	if api.r.Namespaced {
-		ri = dyn.Resource(api.GroupVersionResource()).Namespace(ns)
+	w := newLogWriter(os.Stdout, *noColor)
+	defer w.close()
+	if *outputDir != "" {
+		if err := os.MkdirAll(*outputDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		w.dir = *outputDir
+	}
+	w.timestamps = *timestamps
+	w.format = *output
+	w.cluster = clusterName(c.clientConfig, c.config)
+	w.pretty = *pretty
+	w.highlight = append(append(regexpList{}, include...), highlights...)
+	if *tmpl != "" {
+		t, err := template.New("line").Option("missingkey=zero").Parse(*tmpl)
+		if err != nil {
+			return fmt.Errorf("invalid --template: %w", err)
+		}
+		w.template = t
+	}
+	if *traceLink != "" {
+		t, err := template.New("trace-link").Option("missingkey=zero").Parse(*traceLink)
+		if err != nil {
+			return fmt.Errorf("invalid --trace-link: %w", err)
+		}
+		w.traceTemplate = t
+	}
+	if *selectFields != "" {
+		w.selected = strings.Split(*selectFields, ",")
+	}
+	var stream func(lineWriter) error
+	if opts.follow {
+		tree := newTreeIndex(c.dyn, c.apis, c.ns, t.objs, t.uids)
+		stream = func(lw lineWriter) error { return followLogs(cs, tree, t.root, ctrlPods, opts, lw) }
 	} else {
-		ri = dyn.Resource(api.GroupVersionResource())
+		pods, err := t.pods()
+		if err != nil {
+			return err
+		}
+		pods = podStates.filterPods(pods)
+		if len(pods) == 0 {
+			return fmt.Errorf("no pods found under %s/%s", kind, name)
+		}
+		pods = append(pods, ctrlPods...)
+		if *archive != "" {
+			return writeArchive(*archive, cs, pods, opts, w, t.root, t.objs)
+		}
+		stream = func(lw lineWriter) error { return streamLogs(cs, pods, opts, lw) }
 	}
-	obj, err := ri.Get(context.TODO(), name, metav1.GetOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to get %s/%s: %w", kind, name, err)
+	if *stats {
+		return newLineStats(os.Stdout, *statsInterval).run(stream)
+	}
+	if len(sinks) == 0 {
+		return stream(w)
 	}
+	out := teeWriter{w}
+	var pushed []*pushSink
+	for _, sp := range sinks {
+		ps := newPushSink(sp, w)
+		pushed = append(pushed, ps)
+		out = append(out, ps)
+	}
+	err = stream(out)
+	for _, ps := range pushed {
+		if cerr := ps.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
 
-	apiObjects, err := getAllResources(dyn, apis.resources(), ns)
+// containerRegexp compiles a --container expression, which must match whole container
+// names. It returns nil if expr is empty.
+func containerRegexp(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
 	if err != nil {
-		return fmt.Errorf("error while querying api objects: %w", err)
+		return nil, fmt.Errorf("invalid --container: %w", err)
 	}
+	return re, nil
+}
 
-	objs := newObjectDirectory(apiObjects)
-	if len(objs.ownership[obj.GetUID()]) == 0 {
-		fmt.Println("No resources are owned by this object through ownerReferences.")
-		return nil
+// clusterName returns the name of the kubeconfig cluster in use, or the API server
+// address if it has none (e.g. in-cluster config).
+func clusterName(cc clientcmd.ClientConfig, config *rest.Config) string {
+	raw, err := cc.RawConfig()
+	if err == nil {
+		if ctx := raw.Contexts[raw.CurrentContext]; ctx != nil && ctx.Cluster != "" {
+			return ctx.Cluster
+		}
 	}
-	fmt.Println(objs)
-	return nil
+	return config.Host
 }
 
 // overrideType hardcodes lookup overrides for certain service types
@@ -141,6 +344,67 @@ func newObjectDirectory(objs []unstructured.Unstructured) objectDirectory {
 	return v
 }
 
+// displayName returns the Kind/name form used to refer to an object in output.
+func displayName(o unstructured.Unstructured) string {
+	return o.GetKind() + "/" + o.GetName()
+}
+
+// renderTree returns the objects owned by root as an indented list, one object per line,
+// followed by its nodeNotes in brackets and its nodeDetails on the lines below. If keep
+// is set, objects it rejects are left out along with what they own. If color is set,
+// notes are colored by severity.
+func renderTree(root unstructured.Unstructured, objs objectDirectory, keep func(unstructured.Unstructured) bool, color bool) string {
+	var b strings.Builder
+	now := time.Now()
+	var walk func(o unstructured.Unstructured, depth int, seen map[types.UID]bool)
+	walk = func(o unstructured.Unstructured, depth int, seen map[types.UID]bool) {
+		b.WriteString(strings.Repeat("  ", depth) + displayName(o))
+		for _, n := range nodeNotes(o, now) {
+			if color && n.color != 0 {
+				fmt.Fprintf(&b, "  \x1b[%dm[%s]\x1b[0m", n.color, n.text)
+			} else {
+				b.WriteString("  [" + n.text + "]")
+			}
+		}
+		b.WriteString("\n")
+		for _, d := range nodeDetails(o) {
+			b.WriteString(strings.Repeat("  ", depth+1) + d + "\n")
+		}
+		seen[o.GetUID()] = true
+
+		var children []unstructured.Unstructured
+		for uid := range objs.ownership[o.GetUID()] {
+			if c, ok := objs.items[uid]; ok && !seen[uid] && (keep == nil || keep(c)) {
+				children = append(children, c)
+			}
+		}
+		sort.Slice(children, func(i, j int) bool { return displayName(children[i]) < displayName(children[j]) })
+		for _, c := range children {
+			walk(c, depth+1, seen)
+		}
+	}
+	walk(root, 0, make(map[types.UID]bool))
+	return b.String()
+}
+
+// descendants returns the UIDs of all objects transitively owned by uid.
+func (o objectDirectory) descendants(uid types.UID) map[types.UID]bool {
+	out := make(map[types.UID]bool)
+	queue := []types.UID{uid}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for child := range o.ownership[cur] {
+			if out[child] {
+				continue
+			}
+			out[child] = true
+			queue = append(queue, child)
+		}
+	}
+	return out
+}
+
 // getAllResources finds all API objects in specified API resources in all namespaces (or non-namespaced).
 func getAllResources(client dynamic.Interface, apis []apiResource, ns string) ([]unstructured.Unstructured, error) {
 	var mu sync.Mutex
@@ -170,6 +434,9 @@ func getAllResources(client dynamic.Interface, apis []apiResource, ns string) ([
 	return out, errResult
 }
 
+// listPageSize is the number of objects requested per List call.
+const listPageSize = 250
+
 func queryAPI(client dynamic.Interface, api apiResource, ns string) ([]unstructured.Unstructured, error) {
 	var out []unstructured.Unstructured
 
@@ -180,7 +447,7 @@ func queryAPI(client dynamic.Interface, api apiResource, ns string) ([]unstructu
 		nintf := client.Resource(api.GroupVersionResource())
 		intf = nintf.Namespace(ns)
 		resp, err := intf.List(context.TODO(), metav1.ListOptions{
-			Limit:    250,
+			Limit:    listPageSize,
 			Continue: next,
 		})
 		if err != nil {
@@ -196,6 +463,80 @@ func queryAPI(client dynamic.Interface, api apiResource, ns string) ([]unstructu
 	return out, nil
 }
 
+// countAllResources estimates how many objects getAllResources would fetch by listing a
+// single item of each API resource and reading the remaining item count.
+func countAllResources(client dynamic.Interface, apis []apiResource, ns string) (int64, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var total int64
+
+	var errResult error
+	for _, api := range apis {
+		if !api.r.Namespaced {
+			continue
+		}
+		wg.Add(1)
+		go func(a apiResource) {
+			defer wg.Done()
+			n, err := countAPI(client, a, ns)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errResult = err
+				return
+			}
+			total += n
+		}(api)
+	}
+
+	wg.Wait()
+	return total, errResult
+}
+
+// countAPI returns the number of objects of api in ns. When the server does not report
+// a remaining item count, only the items of the first page are counted.
+func countAPI(client dynamic.Interface, api apiResource, ns string) (int64, error) {
+	resp, err := client.Resource(api.GroupVersionResource()).Namespace(ns).List(context.TODO(), metav1.ListOptions{
+		Limit: 1,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("listing resources failed (%s): %w", api.GroupVersionResource(), err)
+	}
+	n := int64(len(resp.Items))
+	if rem := resp.GetRemainingItemCount(); rem != nil {
+		n += *rem
+	}
+	return n, nil
+}
+
+// printPlan prints the API resources a run would list and a lower bound of the number
+// of API requests it would make, without contacting the cluster beyond discovery.
+func printPlan(root apiResource, name, ns string, apis []apiResource, withCount bool) {
+	var names []string
+	for _, a := range apis {
+		if a.r.Namespaced {
+			names = append(names, fullAPIName(a))
+		}
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Would list %d namespaced API resources in namespace %q:\n", len(names), ns)
+	for _, n := range names {
+		fmt.Printf("  %s\n", n)
+	}
+
+	lists := len(names)
+	if withCount {
+		lists *= 2
+	}
+	fmt.Printf("Estimated API requests: at least %d (1 get of %s/%s, %d lists of up to %d objects per page",
+		1+lists, fullAPIName(root), name, lists, listPageSize)
+	if withCount {
+		fmt.Print(", half of them for --max-objects counting")
+	}
+	fmt.Println(")")
+}
+
 type apiResource struct {
 	r  metav1.APIResource
 	gv schema.GroupVersion
@@ -222,11 +563,55 @@ func (rm *resourceMap) lookup(s string) []apiResource {
 
 func (rm *resourceMap) resources() []apiResource { return rm.list }
 
+// forKind returns the API resource serving kind in apiVersion, as found in ownerReferences
+// and object headers.
+func (rm *resourceMap) forKind(apiVersion, kind string) (apiResource, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return apiResource{}, err
+	}
+	out := rm.lookup(strings.Join([]string{strings.ToLower(kind), gv.Version, gv.Group}, "."))
+	if len(out) == 0 {
+		return apiResource{}, fmt.Errorf("could not find api kind %q", kind)
+	}
+	return out[0], nil
+}
+
+// resourceInterface returns the client for api, scoped to ns if api is namespaced.
+func resourceInterface(client dynamic.Interface, api apiResource, ns string) dynamic.ResourceInterface {
+	if api.r.Namespaced {
+		return client.Resource(api.GroupVersionResource()).Namespace(ns)
+	}
+	return client.Resource(api.GroupVersionResource())
+}
+
 func fullAPIName(a apiResource) string {
 	sgv := a.GroupVersionResource()
 	return strings.Join([]string{sgv.Resource, sgv.Version, sgv.Group}, ".")
 }
 
+// discoveryCacheTTL is how long cached API discovery results are used before refetching.
+const discoveryCacheTTL = 6 * time.Hour
+
+// newDiscoveryClient returns a discovery client that caches results on disk under
+// ~/.kube/cache/tlogs, or only in memory if useDiskCache is false or there is no home directory.
+func newDiscoveryClient(config *rest.Config, useDiskCache bool) (discovery.CachedDiscoveryInterface, error) {
+	home := homedir.HomeDir()
+	if !useDiskCache || home == "" {
+		dc, err := discovery.NewDiscoveryClientForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return memory.NewMemCacheClient(dc), nil
+	}
+
+	// keep a separate cache per API server
+	host := strings.NewReplacer("https://", "", "http://", "").Replace(config.Host)
+	host = regexp.MustCompile(`[^\w.]`).ReplaceAllString(host, "_")
+	dir := filepath.Join(home, ".kube", "cache", "tlogs", "discovery", host)
+	return disk.NewCachedDiscoveryClientForConfig(config, dir, "", discoveryCacheTTL)
+}
+
 func findAPIs(client discovery.DiscoveryInterface) (*resourceMap, error) {
 	resList, err := client.ServerPreferredResources()
 	if err != nil {
@@ -292,5 +677,8 @@ func apiNames(a metav1.APIResource, gv schema.GroupVersion) []string {
 }
 
 func main() {
-	run("workload", "petc", "ns1")
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// metricsLinkData is what a metrics-link template is executed with for each pod.
+type metricsLinkData struct {
+	Namespace string
+	PodName   string
+	Workload  string // name of the root of the tree
+	Labels    map[string]string
+	Selector  string // PromQL label selector for the pod, e.g. {namespace="a",pod="b"}
+}
+
+// printMetricsLinks prints the link rendered from tmpl for every pod of the tree.
+func printMetricsLinks(tmpl *template.Template, root unstructured.Unstructured, pods []corev1.Pod) error {
+	if len(pods) == 0 {
+		fmt.Println("No pods found in the tree.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POD\tLINK")
+	for _, p := range pods {
+		var b strings.Builder
+		err := tmpl.Execute(&b, metricsLinkData{
+			Namespace: p.Namespace,
+			PodName:   p.Name,
+			Workload:  root.GetName(),
+			Labels:    p.Labels,
+			Selector:  fmt.Sprintf("{namespace=%q,pod=%q}", p.Namespace, p.Name),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute metrics link template: %w", err)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", p.Name, b.String())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// certExpiryWarning is how long before it expires a certificate is shown as a warning.
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// generationLagThreshold is how long an object's generation may be ahead of the
+// generation its controller observed before the controller is reported as stuck.
+const generationLagThreshold = 2 * time.Minute
+
+// ANSI foreground colors of notes by severity.
+const (
+	noteWarning = 33
+	noteError   = 31
+)
+
+// nodeNote is a remark about an object, shown next to it in the rendered tree.
+type nodeNote struct {
+	text  string
+	color int // ANSI foreground color if color is on, 0 for none
+}
+
+// nodeNotes returns the remarks shown next to o when the tree is rendered.
+func nodeNotes(o unstructured.Unstructured, now time.Time) []nodeNote {
+	var notes []nodeNote
+	if n := generationLagNote(o, now); n != "" {
+		notes = append(notes, nodeNote{n, noteWarning})
+	}
+	if o.GetKind() == "Pod" && o.GetAPIVersion() == "v1" {
+		for _, n := range podPriorityNotes(o) {
+			c := 0
+			if strings.HasPrefix(n, "preempted") {
+				c = noteWarning
+			}
+			notes = append(notes, nodeNote{n, c})
+		}
+	}
+	if n := terminatingNote(o, now); n != "" {
+		notes = append(notes, nodeNote{n, noteWarning})
+	}
+	if n, ok := certExpiryNote(o, now); ok {
+		notes = append(notes, n)
+	}
+	return notes
+}
+
+// nodeDetails returns the lines shown under o, before what it owns, when the tree is
+// rendered.
+func nodeDetails(o unstructured.Unstructured) []string {
+	if o.GetKind() == "Pod" && o.GetAPIVersion() == "v1" {
+		if b := schedulingBreakdown(o); b != "" {
+			return []string{"scheduling: " + b}
+		}
+	}
+	return nil
+}
+
+// generationLagNote reports o's controller as not reconciling if its generation has
+// been ahead of status.observedGeneration for longer than generationLagThreshold.
+func generationLagNote(o unstructured.Unstructured, now time.Time) string {
+	observed, ok, _ := unstructured.NestedInt64(o.Object, "status", "observedGeneration")
+	if !ok || o.GetGeneration() <= observed {
+		return ""
+	}
+	if now.Sub(lastSpecChange(o)) < generationLagThreshold {
+		return ""
+	}
+	return fmt.Sprintf("controller not reconciling: generation %d, observed %d", o.GetGeneration(), observed)
+}
+
+// lastSpecChange returns when o was last written other than through a subresource,
+// from its managed fields, or its creation time if they do not say.
+func lastSpecChange(o unstructured.Unstructured) time.Time {
+	t := o.GetCreationTimestamp().Time
+	for _, mf := range o.GetManagedFields() {
+		if mf.Subresource == "" && mf.Time != nil && mf.Time.After(t) {
+			t = mf.Time.Time
+		}
+	}
+	return t
+}
+
+// podPriorityNotes returns the PriorityClass of a pod and whether it was preempted, by
+// the scheduler (a DisruptionTarget condition) or by the kubelet for a critical pod.
+func podPriorityNotes(o unstructured.Unstructured) []string {
+	var notes []string
+	if class, _, _ := unstructured.NestedString(o.Object, "spec", "priorityClassName"); class != "" {
+		notes = append(notes, "priority "+class)
+	}
+	if reason, _, _ := unstructured.NestedString(o.Object, "status", "reason"); reason == "Preempting" {
+		return append(notes, "preempted by the kubelet")
+	}
+	if c := podCondition(o, "DisruptionTarget"); c["reason"] == "PreemptionByScheduler" && c["status"] == "True" {
+		notes = append(notes, "preempted by the scheduler")
+	}
+	return notes
+}
+
+// podCondition returns the status condition of pod o with the given type, or nil.
+func podCondition(o unstructured.Unstructured, typ string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(o.Object, "status", "conditions")
+	for _, c := range conditions {
+		if cm, ok := c.(map[string]interface{}); ok && cm["type"] == typ {
+			return cm
+		}
+	}
+	return nil
+}
+
+// unschedulableMessage matches the scheduler's summary of why no node fits a pod, as
+// in its FailedScheduling Events and the pod's PodScheduled condition.
+var unschedulableMessage = regexp.MustCompile(`^(\d+/\d+) nodes are available: (.*?)\.(?: preemption: .*)?$`)
+
+// schedulingReasons shortens the scheduler's reasons for rejecting nodes, in the order
+// they are tried.
+var schedulingReasons = []struct{ match, short string }{
+	{"taint", "taint"},
+	{"didn't match Pod's node affinity/selector", "node affinity"},
+	{"didn't match pod anti-affinity rules", "pod anti-affinity"},
+	{"didn't match pod affinity rules", "pod affinity"},
+	{"didn't match pod topology spread constraints", "topology spread"},
+	{"volume node affinity conflict", "volume affinity"},
+	{"didn't have free ports", "ports"},
+	{"were unschedulable", "unschedulable"},
+	{"Too many pods", "too many pods"},
+}
+
+// schedulingBreakdown returns why a Pending pod could not be scheduled, such as
+// "0/12 nodes: 4 taint, 8 insufficient cpu", or "" if it is not unschedulable.
+func schedulingBreakdown(o unstructured.Unstructured) string {
+	c := podCondition(o, "PodScheduled")
+	if c["status"] != "False" || c["reason"] != "Unschedulable" {
+		return ""
+	}
+	message, _ := c["message"].(string)
+	m := unschedulableMessage.FindStringSubmatch(message)
+	if m == nil {
+		return message
+	}
+	var parts []string
+	for _, r := range strings.Split(m[2], ", ") {
+		count, text, ok := strings.Cut(r, " ")
+		if !ok {
+			parts = append(parts, r)
+			continue
+		}
+		short := strings.ToLower(strings.TrimPrefix(text, "node(s) "))
+		for _, sr := range schedulingReasons {
+			if strings.Contains(text, sr.match) {
+				short = sr.short
+				break
+			}
+		}
+		parts = append(parts, count+" "+short)
+	}
+	return m[1] + " nodes: " + strings.Join(parts, ", ")
+}
+
+// terminatingNote reports how long o has been terminating and the finalizers still
+// holding it, if it is being deleted. The terminating command lists these objects.
+func terminatingNote(o unstructured.Unstructured, now time.Time) string {
+	ts := o.GetDeletionTimestamp()
+	if ts == nil {
+		return ""
+	}
+	finalizers := strings.Join(o.GetFinalizers(), ", ")
+	if finalizers == "" {
+		finalizers = "<none>"
+	}
+	return fmt.Sprintf("terminating for %s, finalizers: %s", duration.HumanDuration(now.Sub(ts.Time)), finalizers)
+}
+
+// certExpiryNote returns when the certificate of a cert-manager Certificate or a TLS
+// Secret expires, from the Certificate's status.notAfter or the Secret's tls.crt.
+func certExpiryNote(o unstructured.Unstructured, now time.Time) (nodeNote, bool) {
+	var notAfter time.Time
+	switch {
+	case o.GetKind() == "Certificate" && strings.HasPrefix(o.GetAPIVersion(), "cert-manager.io/"):
+		s, _, _ := unstructured.NestedString(o.Object, "status", "notAfter")
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nodeNote{}, false
+		}
+		notAfter = t
+	case o.GetKind() == "Secret" && o.GetAPIVersion() == "v1":
+		if typ, _, _ := unstructured.NestedString(o.Object, "type"); typ != "kubernetes.io/tls" {
+			return nodeNote{}, false
+		}
+		data, _, _ := unstructured.NestedString(o.Object, "data", "tls.crt")
+		t, ok := pemNotAfter(data)
+		if !ok {
+			return nodeNote{}, false
+		}
+		notAfter = t
+	default:
+		return nodeNote{}, false
+	}
+
+	left := notAfter.Sub(now)
+	switch {
+	case left <= 0:
+		return nodeNote{"certificate expired " + duration.HumanDuration(-left) + " ago", noteError}, true
+	case left < certExpiryWarning:
+		return nodeNote{"certificate expires in " + duration.HumanDuration(left), noteWarning}, true
+	}
+	return nodeNote{"certificate expires in " + duration.HumanDuration(left), 0}, true
+}
+
+// pemNotAfter returns the expiry of the first certificate in the base64 encoded PEM
+// data, as stored in a Secret.
+func pemNotAfter(data string) (time.Time, bool) {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return time.Time{}, false
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return cert.NotAfter, true
+}
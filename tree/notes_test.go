@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGenerationLagNote(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		generation int64
+		observed   int64 // -1 for no observedGeneration
+		specChange time.Duration
+		want       string
+	}{
+		{"reconciled", 3, 3, time.Hour, ""},
+		{"no observedGeneration", 3, -1, time.Hour, ""},
+		{"lagging briefly", 4, 3, time.Minute, ""},
+		{"stuck", 4, 3, time.Hour, "controller not reconciling: generation 4, observed 3"},
+	}
+	for _, tt := range tests {
+		o := testObject("web")
+		o.SetGeneration(tt.generation)
+		o.SetCreationTimestamp(metav1.NewTime(now.Add(-24 * time.Hour)))
+		changed := metav1.NewTime(now.Add(-tt.specChange))
+		o.SetManagedFields([]metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, Time: &changed},
+			// status writes do not move the generation
+			{Manager: "controller", Operation: metav1.ManagedFieldsOperationUpdate, Subresource: "status", Time: &metav1.Time{Time: now}},
+		})
+		if tt.observed >= 0 {
+			o.Object["status"] = map[string]interface{}{"observedGeneration": tt.observed}
+		}
+		if got := generationLagNote(o, now); got != tt.want {
+			t.Errorf("%s: generationLagNote() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPodPriorityNotes(t *testing.T) {
+	tests := []struct {
+		name   string
+		class  string
+		status map[string]interface{}
+		want   []string
+	}{
+		{"no priority", "", nil, nil},
+		{"priority", "high", nil, []string{"priority high"}},
+		{"preempted by the scheduler", "low", map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "DisruptionTarget", "status": "True", "reason": "PreemptionByScheduler"},
+		}}, []string{"priority low", "preempted by the scheduler"}},
+		{"disrupted otherwise", "", map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"type": "DisruptionTarget", "status": "True", "reason": "EvictionByEvictionAPI"},
+		}}, nil},
+		{"preempted by the kubelet", "", map[string]interface{}{"phase": "Failed", "reason": "Preempting"}, []string{"preempted by the kubelet"}},
+	}
+	for _, tt := range tests {
+		o := testPod("web-1", "Running", "")
+		if tt.class != "" {
+			_ = unstructured.SetNestedField(o.Object, tt.class, "spec", "priorityClassName")
+		}
+		if tt.status != nil {
+			o.Object["status"] = tt.status
+		}
+		if got := podPriorityNotes(o); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: podPriorityNotes() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSchedulingBreakdown(t *testing.T) {
+	tests := []struct {
+		condition map[string]interface{}
+		want      string
+	}{
+		{nil, ""},
+		{map[string]interface{}{"type": "PodScheduled", "status": "True"}, ""},
+		{map[string]interface{}{"type": "PodScheduled", "status": "False", "reason": "Unschedulable",
+			"message": "0/12 nodes are available: 4 node(s) had untolerated taint {dedicated: gpu}, 8 Insufficient cpu."},
+			"0/12 nodes: 4 taint, 8 insufficient cpu"},
+		{map[string]interface{}{"type": "PodScheduled", "status": "False", "reason": "Unschedulable",
+			"message": "0/5 nodes are available: 2 node(s) didn't match Pod's node affinity/selector, 3 node(s) didn't match pod anti-affinity rules. preemption: 0/5 nodes are available: 5 Preemption is not helpful for scheduling."},
+			"0/5 nodes: 2 node affinity, 3 pod anti-affinity"},
+		{map[string]interface{}{"type": "PodScheduled", "status": "False", "reason": "Unschedulable",
+			"message": "0/3 nodes are available: 3 node(s) had volume node affinity conflict."},
+			"0/3 nodes: 3 volume affinity"},
+		{map[string]interface{}{"type": "PodScheduled", "status": "False", "reason": "Unschedulable",
+			"message": "persistentvolumeclaim \"data\" not found"},
+			"persistentvolumeclaim \"data\" not found"},
+	}
+	for _, tt := range tests {
+		o := testPod("web-1", "Pending", "")
+		if tt.condition != nil {
+			_ = unstructured.SetNestedSlice(o.Object, []interface{}{tt.condition}, "status", "conditions")
+		}
+		if got := schedulingBreakdown(o); got != tt.want {
+			t.Errorf("schedulingBreakdown(%v) = %q, want %q", tt.condition, got, tt.want)
+		}
+	}
+}
+
+func TestCertExpiryNote(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	certificate := func(notAfter string) unstructured.Unstructured {
+		o := testObject("web-tls")
+		o.SetAPIVersion("cert-manager.io/v1")
+		o.SetKind("Certificate")
+		if notAfter != "" {
+			_ = unstructured.SetNestedField(o.Object, notAfter, "status", "notAfter")
+		}
+		return o
+	}
+	secret := func(typ string, notAfter time.Time) unstructured.Unstructured {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: now.Add(-time.Hour), NotAfter: notAfter}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crt := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+		o := testObject("web-tls")
+		o.SetKind("Secret")
+		o.Object["type"] = typ
+		o.Object["data"] = map[string]interface{}{"tls.crt": base64.StdEncoding.EncodeToString(crt)}
+		return o
+	}
+
+	tests := []struct {
+		name string
+		o    unstructured.Unstructured
+		want nodeNote
+		ok   bool
+	}{
+		{"certificate", certificate("2024-03-01T12:00:00Z"), nodeNote{"certificate expires in 60d", 0}, true},
+		{"certificate expiring", certificate("2024-01-04T12:00:00Z"), nodeNote{"certificate expires in 3d", noteWarning}, true},
+		{"certificate expired", certificate("2023-12-30T12:00:00Z"), nodeNote{"certificate expired 2d ago", noteError}, true},
+		{"certificate not issued", certificate(""), nodeNote{}, false},
+		{"tls secret", secret("kubernetes.io/tls", now.Add(5*24*time.Hour)), nodeNote{"certificate expires in 5d", noteWarning}, true},
+		{"opaque secret", secret("Opaque", now.Add(5*24*time.Hour)), nodeNote{}, false},
+		{"config map", testObject("web"), nodeNote{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := certExpiryNote(tt.o, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: certExpiryNote() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTerminatingNote(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	o := testObject("web")
+	if got := terminatingNote(o, now); got != "" {
+		t.Errorf("terminatingNote() = %q for an object not being deleted", got)
+	}
+	deleted := metav1.NewTime(now.Add(-90 * time.Minute))
+	o.SetDeletionTimestamp(&deleted)
+	if got, want := terminatingNote(o, now), "terminating for 90m, finalizers: <none>"; got != want {
+		t.Errorf("terminatingNote() = %q, want %q", got, want)
+	}
+	o.SetFinalizers([]string{"kubernetes.io/pvc-protection", "example.com/cleanup"})
+	if got, want := terminatingNote(o, now), "terminating for 90m, finalizers: kubernetes.io/pvc-protection, example.com/cleanup"; got != want {
+		t.Errorf("terminatingNote() = %q, want %q", got, want)
+	}
+}
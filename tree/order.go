@@ -0,0 +1,51 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// lineHeap is a min-heap of log lines by timestamp.
+type lineHeap []logLine
+
+func (h lineHeap) Len() int            { return len(h) }
+func (h lineHeap) Less(i, j int) bool  { return h[i].timestamp.Before(h[j].timestamp) }
+func (h lineHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *lineHeap) Push(x interface{}) { *h = append(*h, x.(logLine)) }
+func (h *lineHeap) Pop() interface{} {
+	old := *h
+	l := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return l
+}
+
+// orderLines merges the lines from in into out ordered by timestamp. Each line is held
+// for at least window after it arrives, so that lines of the other streams written
+// before it can still be sorted ahead of it. out is closed once in is closed and drained.
+func orderLines(in <-chan logLine, out chan<- logLine, window time.Duration) {
+	defer close(out)
+
+	var h lineHeap
+	ticker := time.NewTicker(window / 4)
+	defer ticker.Stop()
+
+	flush := func(all bool) {
+		cutoff := time.Now().Add(-window)
+		for h.Len() > 0 && (all || !h[0].received.After(cutoff)) {
+			out <- heap.Pop(&h).(logLine)
+		}
+	}
+	for {
+		select {
+		case l, ok := <-in:
+			if !ok {
+				flush(true)
+				return
+			}
+			l.received = time.Now()
+			heap.Push(&h, l)
+		case <-ticker.C:
+			flush(false)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderLines(t *testing.T) {
+	base := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	offsets := []int{3, 1, 4, 0, 2}
+
+	in := make(chan logLine)
+	out := make(chan logLine)
+	go orderLines(in, out, time.Hour)
+	go func() {
+		for _, o := range offsets {
+			in <- logLine{text: string(rune('a' + o)), timestamp: base.Add(time.Duration(o) * time.Second)}
+		}
+		close(in)
+	}()
+
+	var got []logLine
+	for l := range out {
+		got = append(got, l)
+	}
+	if len(got) != len(offsets) {
+		t.Fatalf("got %d lines, want %d", len(got), len(offsets))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].timestamp.Before(got[i-1].timestamp) {
+			t.Errorf("line %q at %s came after %q at %s", got[i].text, got[i].timestamp, got[i-1].text, got[i-1].timestamp)
+		}
+	}
+}
+
+func TestOrderLinesReleasesAfterWindow(t *testing.T) {
+	in := make(chan logLine)
+	out := make(chan logLine)
+	go orderLines(in, out, 40*time.Millisecond)
+	defer close(in)
+
+	in <- logLine{text: "a", timestamp: time.Now()}
+	select {
+	case l := <-out:
+		if l.text != "a" {
+			t.Errorf("got %q, want %q", l.text, "a")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("line was not released after the order window")
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/mattn/go-isatty"
+)
+
+// prefixColors are the ANSI foreground colors assigned to log sources.
+var prefixColors = []int{31, 32, 33, 34, 35, 36, 91, 92, 93, 94, 95, 96}
+
+// logWriter writes merged log lines, prefixed with the namespace/pod/container they came from.
+type logWriter struct {
+	out        io.Writer
+	format     string // text, json or logfmt
+	cluster    string // reported in structured records
+	color      bool
+	timestamps bool               // show the timestamp of lines that have one
+	selected   []string           // only show these fields of JSON lines, flattened, if set
+	pretty     bool               // indent JSON lines
+	highlight  []*regexp.Regexp   // color matches of these in the message, if color is on
+	template   *template.Template // renders each line in text format, if set
+
+	traceTemplate *template.Template // renders a link for lines with a trace ID, if set
+
+	dir   string              // write one file per container here instead of to out, if set
+	files map[string]*os.File // open files in dir by name
+}
+
+// newLogWriter returns a logWriter for out that uses colors as useColor decides.
+func newLogWriter(out *os.File, noColor bool) *logWriter {
+	return &logWriter{
+		out:    out,
+		format: "text",
+		color:  useColor(out, noColor),
+		files:  make(map[string]*os.File),
+	}
+}
+
+// useColor reports whether to color output to out: if it is a terminal, unless noColor
+// is set or NO_COLOR is present in the environment.
+func useColor(out *os.File, noColor bool) bool {
+	_, envNoColor := os.LookupEnv("NO_COLOR")
+	tty := isatty.IsTerminal(out.Fd()) || isatty.IsCygwinTerminal(out.Fd())
+	return tty && !noColor && !envNoColor
+}
+
+func (w *logWriter) write(l logLine) error {
+	out, err := w.dest(l)
+	if err != nil {
+		return err
+	}
+	switch w.format {
+	case "json":
+		return w.writeJSON(out, l)
+	case "logfmt":
+		return w.writeLogfmt(out, l)
+	}
+
+	if w.template != nil {
+		return w.writeTemplate(out, l)
+	}
+
+	var parts []string
+	if w.dir == "" {
+		parts = append(parts, w.prefix(l))
+	}
+	if w.timestamps && !l.timestamp.IsZero() {
+		parts = append(parts, l.timestamp.Format(time.RFC3339Nano))
+	}
+	msg := w.message(l)
+	if w.color {
+		msg = highlight(msg, w.highlight)
+	}
+	parts = append(parts, msg)
+	if link := w.traceLink(l); link != "" {
+		parts = append(parts, "trace="+link)
+	}
+	_, err = fmt.Fprintln(out, strings.Join(parts, " "))
+	return err
+}
+
+// dest returns where l is written: the file of its container when writing to a
+// directory, which is created on first use, and w.out otherwise.
+func (w *logWriter) dest(l logLine) (io.Writer, error) {
+	if w.dir == "" {
+		return w.out, nil
+	}
+	name := fmt.Sprintf("%s_%s_%s.log", l.pod.Namespace, l.pod.Name, l.container)
+	if f, ok := w.files[name]; ok {
+		return f, nil
+	}
+	f, err := os.Create(filepath.Join(w.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %w", err)
+	}
+	w.files[name] = f
+	return f, nil
+}
+
+// close closes the files written to by w.
+func (w *logWriter) close() error {
+	var errResult error
+	for _, f := range w.files {
+		if err := f.Close(); err != nil {
+			errResult = err
+		}
+	}
+	return errResult
+}
+
+// logRecord is the NDJSON form of a log line.
+type logRecord struct {
+	Cluster    string                 `json:"cluster,omitempty"`
+	Namespace  string                 `json:"namespace"`
+	Pod        string                 `json:"pod"`
+	Container  string                 `json:"container"`
+	Timestamp  *time.Time             `json:"timestamp,omitempty"`
+	Message    string                 `json:"message"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Repeated   int                    `json:"repeated,omitempty"`
+	TraceLink  string                 `json:"traceLink,omitempty"`
+	Controller bool                   `json:"controller,omitempty"`
+}
+
+func (w *logWriter) writeJSON(out io.Writer, l logLine) error {
+	rec := logRecord{
+		Cluster:    w.cluster,
+		Namespace:  l.pod.Namespace,
+		Pod:        l.pod.Name,
+		Container:  l.container,
+		Message:    l.text,
+		Fields:     l.fields,
+		TraceLink:  w.traceLink(l),
+		Controller: l.controller,
+	}
+	if l.repeats > 1 {
+		rec.Repeated = l.repeats
+	}
+	if !l.timestamp.IsZero() {
+		rec.Timestamp = &l.timestamp
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", b)
+	return err
+}
+
+// writeLogfmt writes l as a logfmt line of its source, timestamp and message, followed
+// by the top-level fields of JSON lines in key order. Field keys are prefixed with
+// "field." so that they cannot collide with the keys written by tlogs.
+func (w *logWriter) writeLogfmt(out io.Writer, l logLine) error {
+	var b strings.Builder
+	pair := func(k, v string) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		if v == "" || strings.ContainsAny(v, " =\"\\") || strings.IndexFunc(v, unicode.IsControl) >= 0 {
+			v = strconv.Quote(v)
+		}
+		b.WriteString(v)
+	}
+	if w.cluster != "" {
+		pair("cluster", w.cluster)
+	}
+	pair("namespace", l.pod.Namespace)
+	pair("pod", l.pod.Name)
+	pair("container", l.container)
+	if l.controller {
+		pair("controller", "true")
+	}
+	if !l.timestamp.IsZero() {
+		pair("ts", l.timestamp.Format(time.RFC3339Nano))
+	}
+	pair("msg", l.text)
+	if l.repeats > 1 {
+		pair("repeated", strconv.Itoa(l.repeats))
+	}
+	if link := w.traceLink(l); link != "" {
+		pair("trace_link", link)
+	}
+	var keys []string
+	for k := range l.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pair("field."+logfmtKey(k), fieldString(l.fields[k]))
+	}
+	_, err := fmt.Fprintln(out, b.String())
+	return err
+}
+
+// logfmtKey replaces the characters that cannot appear in a logfmt key with '_'.
+func logfmtKey(k string) string {
+	if k == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || unicode.IsControl(r) || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, k)
+}
+
+// templateData is what a --template is executed with for each line.
+type templateData struct {
+	Cluster       string
+	Namespace     string
+	PodName       string
+	ContainerName string
+	Labels        map[string]string
+	Timestamp     time.Time
+	Message       string
+	Fields        map[string]interface{}
+	TraceLink     string
+	Controller    bool
+}
+
+func (w *logWriter) writeTemplate(out io.Writer, l logLine) error {
+	var b strings.Builder
+	err := w.template.Execute(&b, templateData{
+		Cluster:       w.cluster,
+		Namespace:     l.pod.Namespace,
+		PodName:       l.pod.Name,
+		ContainerName: l.container,
+		Labels:        l.pod.Labels,
+		Timestamp:     l.timestamp,
+		Message:       w.message(l),
+		Fields:        l.fields,
+		TraceLink:     w.traceLink(l),
+		Controller:    l.controller,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	_, err = fmt.Fprintln(out, strings.TrimSuffix(b.String(), "\n"))
+	return err
+}
+
+// message returns the text of l, with JSON lines flattened or indented as configured
+// and the repeat count of deduplicated lines.
+func (w *logWriter) message(l logLine) string {
+	if l.repeats > 1 {
+		return fmt.Sprintf("%s (repeated %d times)", w.fieldsMessage(l), l.repeats)
+	}
+	return w.fieldsMessage(l)
+}
+
+// fieldsMessage returns the text of l, with JSON lines flattened or indented as configured.
+func (w *logWriter) fieldsMessage(l logLine) string {
+	switch {
+	case l.fields == nil:
+		return l.text
+	case len(w.selected) > 0:
+		return flattenFields(l.fields, w.selected)
+	case w.pretty:
+		return prettyJSON(l.text)
+	default:
+		return l.text
+	}
+}
+
+// prefix returns the source of l, colored consistently for the same pod and container.
+func (w *logWriter) prefix(l logLine) string {
+	p := l.pod.Namespace + "/" + l.pod.Name + "/" + l.container
+	label := ""
+	if l.controller {
+		label = "[controller] "
+	}
+	if !w.color {
+		return label + p
+	}
+	h := fnv.New32a()
+	h.Write([]byte(p))
+	return fmt.Sprintf("%s\x1b[%dm%s\x1b[0m", label, prefixColors[h.Sum32()%uint32(len(prefixColors))], p)
+}
+
+// highlight colors every match of patterns in s. Matches are found in the original
+// text and overlapping ones merged, so patterns never match the inserted escape codes.
+func highlight(s string, patterns []*regexp.Regexp) string {
+	var spans [][]int
+	for _, re := range patterns {
+		for _, m := range re.FindAllStringIndex(s, -1) {
+			if m[0] < m[1] {
+				spans = append(spans, m)
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return s
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(spans); i++ {
+		start, end := spans[i][0], spans[i][1]
+		for i+1 < len(spans) && spans[i+1][0] <= end {
+			i++
+			if spans[i][1] > end {
+				end = spans[i][1]
+			}
+		}
+		b.WriteString(s[last:start])
+		b.WriteString("\x1b[1;31m" + s[start:end] + "\x1b[0m")
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
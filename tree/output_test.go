@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHighlight(t *testing.T) {
+	const on, off = "\x1b[1;31m", "\x1b[0m"
+	tests := []struct {
+		desc     string
+		s        string
+		patterns []string
+		want     string
+	}{
+		{"no patterns", "error here", nil, "error here"},
+		{"no match", "all good", []string{"error"}, "all good"},
+		{"single match", "an error here", []string{"error"}, "an " + on + "error" + off + " here"},
+		{"every match", "error, error", []string{"error"}, on + "error" + off + ", " + on + "error" + off},
+		{"overlapping patterns merged", "timeout error", []string{"timeout e", "error"}, on + "timeout error" + off},
+		{"contained match merged", "fatal error", []string{"fatal error", "error"}, on + "fatal error" + off},
+		{"adjacent matches merged", "abcd", []string{"ab", "cd"}, on + "abcd" + off},
+		{"escape codes not matched", "m", []string{"m", `\[`}, on + "m" + off},
+		{"empty matches ignored", "abc", []string{"x*"}, "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var res []*regexp.Regexp
+			for _, p := range tt.patterns {
+				res = append(res, regexp.MustCompile(p))
+			}
+			if got := highlight(tt.s, res); got != tt.want {
+				t.Errorf("highlight(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteLogfmt(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-1"}}
+	fields := func(s string) map[string]interface{} {
+		f, ok := parseJSONLine(s)
+		if !ok {
+			t.Fatalf("not a JSON line: %s", s)
+		}
+		return f
+	}
+	tests := []struct {
+		desc string
+		l    logLine
+		want string
+	}{
+		{"plain line", logLine{pod: pod, container: "app", text: "started"},
+			`namespace=shop pod=web-1 container=app msg=started`},
+		{"quoted message", logLine{pod: pod, container: "app", text: `a "b" c=d`},
+			`namespace=shop pod=web-1 container=app msg="a \"b\" c=d"`},
+		{"controller line", logLine{pod: pod, container: "manager", text: "reconciled", controller: true},
+			`namespace=shop pod=web-1 container=manager controller=true msg=reconciled`},
+		{"empty message", logLine{pod: pod, container: "app"},
+			`namespace=shop pod=web-1 container=app msg=""`},
+		{"timestamp and repeats", logLine{pod: pod, container: "app", text: "ping",
+			timestamp: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC), repeats: 3},
+			`namespace=shop pod=web-1 container=app ts=2022-06-01T12:00:00Z msg=ping repeated=3`},
+		{"fields do not collide with reserved keys",
+			logLine{pod: pod, container: "app", text: `{"msg":"hi","ts":1,"level":"info"}`,
+				fields: fields(`{"msg":"hi","ts":1,"level":"info"}`)},
+			`namespace=shop pod=web-1 container=app msg="{\"msg\":\"hi\",\"ts\":1,\"level\":\"info\"}" field.level=info field.msg=hi field.ts=1`},
+		{"field keys are sanitised", logLine{pod: pod, container: "app", text: "{}",
+			fields: map[string]interface{}{"a b": "1", "x=y": "2", `q"`: "3", "": "4"}},
+			`namespace=shop pod=web-1 container=app msg={} field._=4 field.a_b=1 field.q_=3 field.x_y=2`},
+		{"nested field values", logLine{pod: pod, container: "app", text: "{}",
+			fields: map[string]interface{}{"req": map[string]interface{}{"id": json.Number("7")}}},
+			`namespace=shop pod=web-1 container=app msg={} field.req="{\"id\":7}"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var b strings.Builder
+			w := &logWriter{out: &b, format: "logfmt"}
+			if err := w.writeLogfmt(&b, tt.l); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSuffix(b.String(), "\n"); got != tt.want {
+				t.Errorf("writeLogfmt() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// pauseFields are the boolean fields that stop reconciliation of a kind while set.
+var pauseFields = map[schema.GroupKind][]string{
+	{Group: "apps", Kind: "Deployment"}:                               {"spec", "paused"},
+	{Group: "batch", Kind: "CronJob"}:                                 {"spec", "suspend"},
+	{Group: "kustomize.toolkit.fluxcd.io", Kind: "Kustomization"}:     {"spec", "suspend"},
+	{Group: "helm.toolkit.fluxcd.io", Kind: "HelmRelease"}:            {"spec", "suspend"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "GitRepository"}:        {"spec", "suspend"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "HelmRepository"}:       {"spec", "suspend"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "OCIRepository"}:        {"spec", "suspend"},
+	{Group: "source.toolkit.fluxcd.io", Kind: "Bucket"}:               {"spec", "suspend"},
+	{Group: "image.toolkit.fluxcd.io", Kind: "ImageRepository"}:       {"spec", "suspend"},
+	{Group: "image.toolkit.fluxcd.io", Kind: "ImageUpdateAutomation"}: {"spec", "suspend"},
+}
+
+// argoApplication has no pause field; automated sync is removed instead and kept in
+// syncPolicyAnnotation so that it can be restored.
+var argoApplication = schema.GroupKind{Group: "argoproj.io", Kind: "Application"}
+
+const syncPolicyAnnotation = "tlogs.atmandhol.io/paused-sync-policy"
+
+// pauseTree pauses reconciliation of every supported object in the tree, or resumes it
+// if resume is set. Nothing is changed unless confirm is set; the objects are listed instead.
+func pauseTree(client dynamic.Interface, apis *resourceMap, ns string, objs objectDirectory, uids map[types.UID]bool, resume, confirm bool) error {
+	action := "pause"
+	if resume {
+		action = "resume"
+	}
+
+	var targets []unstructured.Unstructured
+	for uid := range uids {
+		o, ok := objs.items[uid]
+		if !ok {
+			continue
+		}
+		gk := o.GroupVersionKind().GroupKind()
+		if _, ok := pauseFields[gk]; ok || gk == argoApplication {
+			targets = append(targets, o)
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Println("No objects with a supported pause field found in the tree.")
+		return nil
+	}
+	sort.Slice(targets, func(i, j int) bool { return displayName(targets[i]) < displayName(targets[j]) })
+
+	if !confirm {
+		for _, o := range targets {
+			fmt.Printf("would %s %s\n", action, displayName(o))
+		}
+		fmt.Println("Nothing was changed, run again with --yes to proceed.")
+		return nil
+	}
+
+	for _, o := range targets {
+		patch, err := pausePatch(o, resume)
+		if err != nil {
+			return fmt.Errorf("failed to %s %s: %w", action, displayName(o), err)
+		}
+		if patch == nil {
+			fmt.Printf("%s has nothing to %s\n", displayName(o), action)
+			continue
+		}
+		api, err := apis.forKind(o.GetAPIVersion(), o.GetKind())
+		if err != nil {
+			return err
+		}
+		_, err = resourceInterface(client, api, ns).Patch(context.TODO(), o.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to %s %s: %w", action, displayName(o), err)
+		}
+		fmt.Printf("%sd %s\n", action, displayName(o))
+	}
+	return nil
+}
+
+// pausePatch returns the merge patch that pauses o, or resumes it if resume is set.
+// It returns nil if o is already in the requested state.
+func pausePatch(o unstructured.Unstructured, resume bool) ([]byte, error) {
+	patch := map[string]interface{}{}
+	gk := o.GroupVersionKind().GroupKind()
+	if gk != argoApplication {
+		paused, _, _ := unstructured.NestedBool(o.Object, pauseFields[gk]...)
+		if paused != resume {
+			return nil, nil
+		}
+		if err := unstructured.SetNestedField(patch, !resume, pauseFields[gk]...); err != nil {
+			return nil, err
+		}
+		return json.Marshal(patch)
+	}
+
+	saved, isPaused := o.GetAnnotations()[syncPolicyAnnotation]
+	if resume {
+		if !isPaused {
+			return nil, nil
+		}
+		var automated interface{}
+		if err := json.Unmarshal([]byte(saved), &automated); err != nil {
+			return nil, fmt.Errorf("invalid %s annotation: %w", syncPolicyAnnotation, err)
+		}
+		patch["metadata"] = map[string]interface{}{"annotations": map[string]interface{}{syncPolicyAnnotation: nil}}
+		patch["spec"] = map[string]interface{}{"syncPolicy": map[string]interface{}{"automated": automated}}
+		return json.Marshal(patch)
+	}
+
+	automated, found, _ := unstructured.NestedFieldNoCopy(o.Object, "spec", "syncPolicy", "automated")
+	if isPaused || !found {
+		return nil, nil
+	}
+	b, err := json.Marshal(automated)
+	if err != nil {
+		return nil, err
+	}
+	patch["metadata"] = map[string]interface{}{"annotations": map[string]interface{}{syncPolicyAnnotation: string(b)}}
+	patch["spec"] = map[string]interface{}{"syncPolicy": map[string]interface{}{"automated": nil}}
+	return json.Marshal(patch)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPausePatch(t *testing.T) {
+	deployment := func(paused interface{}) unstructured.Unstructured {
+		o := unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+		o.SetAPIVersion("apps/v1")
+		o.SetKind("Deployment")
+		if paused != nil {
+			o.Object["spec"].(map[string]interface{})["paused"] = paused
+		}
+		return o
+	}
+	tests := []struct {
+		desc   string
+		o      unstructured.Unstructured
+		resume bool
+		want   string
+	}{
+		{"pause unset", deployment(nil), false, `{"spec":{"paused":true}}`},
+		{"pause running", deployment(false), false, `{"spec":{"paused":true}}`},
+		{"pause paused", deployment(true), false, ""},
+		{"resume paused", deployment(true), true, `{"spec":{"paused":false}}`},
+		{"resume running", deployment(false), true, ""},
+		{"resume unset", deployment(nil), true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			patch, err := pausePatch(tt.o, tt.resume)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(patch) != tt.want {
+				t.Errorf("pausePatch() = %s, want %s", patch, tt.want)
+			}
+		})
+	}
+}
+
+// applyMergePatch applies the JSON merge patch to obj in place.
+func applyMergePatch(obj, patch map[string]interface{}) {
+	for k, v := range patch {
+		switch v := v.(type) {
+		case nil:
+			delete(obj, k)
+		case map[string]interface{}:
+			sub, ok := obj[k].(map[string]interface{})
+			if !ok {
+				sub = map[string]interface{}{}
+				obj[k] = sub
+			}
+			applyMergePatch(sub, v)
+		default:
+			obj[k] = v
+		}
+	}
+}
+
+func TestPausePatchArgoRoundTrip(t *testing.T) {
+	var app unstructured.Unstructured
+	app.SetAPIVersion("argoproj.io/v1alpha1")
+	app.SetKind("Application")
+	automated := map[string]interface{}{"prune": true, "selfHeal": true}
+	if err := unstructured.SetNestedField(app.Object, automated, "spec", "syncPolicy", "automated"); err != nil {
+		t.Fatal(err)
+	}
+	want := app.DeepCopy()
+
+	patch := func(resume bool) []byte {
+		t.Helper()
+		b, err := pausePatch(app, resume)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != nil {
+			var p map[string]interface{}
+			if err := json.Unmarshal(b, &p); err != nil {
+				t.Fatal(err)
+			}
+			applyMergePatch(app.Object, p)
+		}
+		return b
+	}
+
+	if patch(false) == nil {
+		t.Fatal("pausing an automated Application returned no patch")
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(app.Object, "spec", "syncPolicy", "automated"); found {
+		t.Errorf("automated sync still set after pausing: %v", app.Object)
+	}
+	if _, ok := app.GetAnnotations()[syncPolicyAnnotation]; !ok {
+		t.Errorf("%s annotation not set after pausing", syncPolicyAnnotation)
+	}
+	if b := patch(false); b != nil {
+		t.Errorf("pausing a paused Application returned %s", b)
+	}
+
+	if patch(true) == nil {
+		t.Fatal("resuming a paused Application returned no patch")
+	}
+	if _, ok := app.GetAnnotations()[syncPolicyAnnotation]; ok {
+		t.Errorf("%s annotation still set after resuming", syncPolicyAnnotation)
+	}
+	if !reflect.DeepEqual(app.Object["spec"], want.Object["spec"]) {
+		t.Errorf("after pause and resume got spec %v, want %v", app.Object["spec"], want.Object["spec"])
+	}
+	if b := patch(true); b != nil {
+		t.Errorf("resuming a running Application returned %s", b)
+	}
+}
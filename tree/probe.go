@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// probeScript resolves $0 and connects to port $1 of it, exiting with 10 if resolving
+// fails and 11 if connecting fails.
+const probeScript = `if command -v getent >/dev/null; then getent hosts "$0"; else nslookup "$0"; fi || exit 10
+nc -z -w 3 "$0" "$1" || exit 11`
+
+// probeToolsScript succeeds if the tools used by probeScript are available.
+const probeToolsScript = `command -v nc && { command -v getent || command -v nslookup; }`
+
+// parseProbeTarget returns the host and port of a probe target, which is either
+// svc/NAME:PORT for a service in ns or HOST:PORT.
+func parseProbeTarget(target, ns string) (string, string, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid probe target %q, expected svc/NAME:PORT or HOST:PORT", target)
+	}
+	if name := strings.TrimPrefix(host, "svc/"); name != host {
+		host = name + "." + ns + ".svc"
+	}
+	return host, port, nil
+}
+
+// probeFromPod checks that target resolves and accepts connections from pod, a pod of
+// the tree, by running shell tools in its first container matching container. If the
+// image lacks them and confirm is set, an ephemeral container running image is added
+// to the pod and used instead.
+func probeFromPod(config *rest.Config, client corev1client.CoreV1Interface, pods []corev1.Pod, podName, target, ns string, container *regexp.Regexp, image string, confirm bool) error {
+	var pod *corev1.Pod
+	for i := range pods {
+		if pods[i].Name == podName {
+			pod = &pods[i]
+		}
+	}
+	if pod == nil {
+		return fmt.Errorf("pod %q is not part of the tree", podName)
+	}
+	host, port, err := parseProbeTarget(target, ns)
+	if err != nil {
+		return err
+	}
+
+	c, err := execContainer(pod, container)
+	if err != nil {
+		return err
+	}
+	var discard bytes.Buffer
+	if err := podExec(config, client, pod, c, []string{"sh", "-c", probeToolsScript}, nil, &discard, &discard); err != nil {
+		if !confirm {
+			return fmt.Errorf("container %s of pod %s lacks sh, nc or getent/nslookup, run again with --yes to add an ephemeral %s container", c, pod.Name, image)
+		}
+		if c, err = addDebugContainer(client, pod, image); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("probing %s:%s from pod/%s (container %s)\n", host, port, pod.Name, c)
+	var out bytes.Buffer
+	err = podExec(config, client, pod, c, []string{"sh", "-c", probeScript, host, port}, nil, &out, &out)
+	var exitErr utilexec.CodeExitError
+	switch {
+	case err == nil:
+		fmt.Printf("dns:     ok\n%s", indent(out.String()))
+		fmt.Println("connect: ok")
+	case errors.As(err, &exitErr) && exitErr.Code == 10:
+		fmt.Printf("dns:     failed\n%s", indent(out.String()))
+	case errors.As(err, &exitErr) && exitErr.Code == 11:
+		fmt.Println("dns:     ok")
+		fmt.Printf("connect: failed\n%s", indent(out.String()))
+	default:
+		return fmt.Errorf("failed to run probe: %w", err)
+	}
+	return nil
+}
+
+// indent indents every line of s for display under a probe result.
+func indent(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ") + "\n"
+}
+
+// addDebugContainer adds an idle ephemeral container running image to pod and waits for
+// it to start. Ephemeral containers cannot be removed, it stays until the pod is deleted.
+func addDebugContainer(client corev1client.CoreV1Interface, pod *corev1.Pod, image string) (string, error) {
+	name := fmt.Sprintf("tlogs-probe-%d", time.Now().Unix())
+	p := pod.DeepCopy()
+	p.Spec.EphemeralContainers = append(p.Spec.EphemeralContainers, corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:    name,
+			Image:   image,
+			Command: []string{"sleep", "3600"},
+		},
+	})
+	if _, err := client.Pods(pod.Namespace).UpdateEphemeralContainers(context.TODO(), pod.Name, p, metav1.UpdateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to add ephemeral container to pod %s: %w", pod.Name, err)
+	}
+	fmt.Printf("added ephemeral container %s to pod/%s\n", name, pod.Name)
+
+	for i := 0; i < 60; i++ {
+		p, err := client.Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get pod %s: %w", pod.Name, err)
+		}
+		for _, cs := range p.Status.EphemeralContainerStatuses {
+			if cs.Name == name && cs.State.Running != nil {
+				return name, nil
+			}
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return "", fmt.Errorf("ephemeral container %s of pod %s did not start", name, pod.Name)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseProbeTarget(t *testing.T) {
+	tests := []struct {
+		target   string
+		wantHost string
+		wantPort string
+		wantErr  bool
+	}{
+		{"svc/web:8080", "web.shop.svc", "8080", false},
+		{"db.example.com:5432", "db.example.com", "5432", false},
+		{"10.0.0.1:80", "10.0.0.1", "80", false},
+		{"[fd00::1]:443", "fd00::1", "443", false},
+		{"svc/web", "", "", true},
+		{"web", "", "", true},
+	}
+	for _, tt := range tests {
+		host, port, err := parseProbeTarget(tt.target, "shop")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseProbeTarget(%q) error = %v, want error %v", tt.target, err, tt.wantErr)
+			continue
+		}
+		if host != tt.wantHost || port != tt.wantPort {
+			t.Errorf("parseProbeTarget(%q) = %q, %q, want %q, %q", tt.target, host, port, tt.wantHost, tt.wantPort)
+		}
+	}
+}
+
+func TestProbeFailures(t *testing.T) {
+	event := func(uid, reason, message, fieldPath string, count int64, first, last string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata":       map[string]interface{}{"uid": uid},
+			"reason":         reason,
+			"message":        message,
+			"count":          count,
+			"firstTimestamp": first,
+			"lastTimestamp":  last,
+			"involvedObject": map[string]interface{}{"kind": "Pod", "name": "web-1", "fieldPath": fieldPath},
+		}}
+	}
+	p := make(probeFailures)
+	p.observe(event("a", "Unhealthy", "Liveness probe failed: HTTP probe failed with statuscode: 500", "spec.containers{app}", 3, "2024-01-01T10:00:00Z", "2024-01-01T10:02:00Z"))
+	// a later update of the same Event replaces its count
+	p.observe(event("a", "Unhealthy", "Liveness probe failed: HTTP probe failed with statuscode: 500", "spec.containers{app}", 4, "2024-01-01T10:00:00Z", "2024-01-01T10:03:00Z"))
+	p.observe(event("b", "Unhealthy", "Readiness probe errored: rpc error", "spec.containers{app}", 1, "2024-01-01T10:01:00Z", "2024-01-01T10:01:00Z"))
+	p.observe(event("c", "BackOff", "Back-off restarting failed container", "spec.containers{app}", 5, "2024-01-01T10:00:00Z", "2024-01-01T10:05:00Z"))
+	p.observe(event("d", "Unhealthy", "Pod sandbox changed", "", 1, "2024-01-01T10:00:00Z", "2024-01-01T10:00:00Z"))
+
+	if len(p) != 2 {
+		t.Fatalf("got %d series, want 2: %v", len(p), p)
+	}
+	liveness := p[probeKey{"web-1", "app", "liveness"}]
+	if liveness == nil {
+		t.Fatal("no liveness series")
+	}
+	if got := liveness.total(); got != 4 {
+		t.Errorf("liveness failures = %d, want 4", got)
+	}
+	if got := liveness.meanTimeBetween(); got != time.Minute {
+		t.Errorf("liveness mean time between = %s, want 1m", got)
+	}
+	readiness := p[probeKey{"web-1", "app", "readiness"}]
+	if readiness == nil {
+		t.Fatal("no readiness series")
+	}
+	if got := readiness.meanTimeBetween(); got != 0 {
+		t.Errorf("readiness mean time between = %s, want 0 for a single failure", got)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/dynamic"
+)
+
+// probeKey identifies the probe of a container.
+type probeKey struct {
+	pod, container, probe string
+}
+
+// probeFailures counts the failures of each probe, from the Unhealthy Events seen while
+// events are streamed.
+type probeFailures map[probeKey]*probeFailureSeries
+
+type probeFailureSeries struct {
+	counts      map[types.UID]int64 // count of each Event object
+	first, last time.Time
+}
+
+// observe records u if it reports a failed probe.
+func (p probeFailures) observe(u *unstructured.Unstructured) {
+	if eventReason(u) != "Unhealthy" {
+		return
+	}
+	message, _, _ := unstructured.NestedString(u.Object, "message")
+	probe, _, ok := strings.Cut(message, " probe ")
+	if !ok || strings.ContainsAny(probe, " :") {
+		return
+	}
+	fieldPath, _, _ := unstructured.NestedString(u.Object, "involvedObject", "fieldPath")
+	k := probeKey{pod: involvedObject(u).Name, container: fieldPathContainer(fieldPath), probe: strings.ToLower(probe)}
+	s := p[k]
+	if s == nil {
+		s = &probeFailureSeries{counts: make(map[types.UID]int64)}
+		p[k] = s
+	}
+	s.counts[u.GetUID()] = eventCount(u)
+	if t := eventFirstTime(u); s.first.IsZero() || t.Before(s.first) {
+		s.first = t
+	}
+	if t := eventLastTime(u); t.After(s.last) {
+		s.last = t
+	}
+}
+
+func (s *probeFailureSeries) total() int64 {
+	var n int64
+	for _, c := range s.counts {
+		n += c
+	}
+	return n
+}
+
+// meanTimeBetween returns the mean time between the failures of s, or 0 if it failed once.
+func (s *probeFailureSeries) meanTimeBetween() time.Duration {
+	n := s.total()
+	if n < 2 || !s.last.After(s.first) {
+		return 0
+	}
+	return s.last.Sub(s.first) / time.Duration(n-1)
+}
+
+// print prints a table of the failed probes, with the current restart count of their
+// container from restarts, most failures first.
+func (p probeFailures) print(restarts func(pod, container string) string) error {
+	if len(p) == 0 {
+		fmt.Println("No probe failures seen.")
+		return nil
+	}
+	keys := make([]probeKey, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if a, b := p[keys[i]].total(), p[keys[j]].total(); a != b {
+			return a > b
+		}
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "POD\tCONTAINER\tPROBE\tFAILURES\tMEAN TIME BETWEEN\tRESTARTS")
+	for _, k := range keys {
+		s := p[k]
+		mtbf := "-"
+		if d := s.meanTimeBetween(); d > 0 {
+			mtbf = duration.HumanDuration(d)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", k.pod, orDash(k.container), k.probe, s.total(), mtbf, restarts(k.pod, k.container))
+	}
+	return w.Flush()
+}
+
+// containerRestarts returns the current restart count of a container, or "-" if the pod
+// or container is gone.
+func containerRestarts(client dynamic.Interface, ns, pod, container string) string {
+	u, err := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace(ns).Get(context.TODO(), pod, metav1.GetOptions{})
+	if err != nil {
+		return "-"
+	}
+	for _, f := range []string{"containerStatuses", "initContainerStatuses"} {
+		statuses, _, _ := unstructured.NestedSlice(u.Object, "status", f)
+		for _, st := range statuses {
+			m, ok := st.(map[string]interface{})
+			if !ok || m["name"] != container {
+				continue
+			}
+			n, _, _ := unstructured.NestedInt64(m, "restartCount")
+			return fmt.Sprint(n)
+		}
+	}
+	return "-"
+}
+
+// fieldPathContainer returns the container name of an Event's fieldPath, such as
+// spec.containers{app}, or "" if it does not refer to a container.
+func fieldPathContainer(fieldPath string) string {
+	_, rest, ok := strings.Cut(fieldPath, "{")
+	if !ok {
+		return ""
+	}
+	name, _, _ := strings.Cut(rest, "}")
+	return name
+}
+
+// eventLastTime returns when an Event last occurred.
+func eventLastTime(u *unstructured.Unstructured) time.Time {
+	for _, f := range [][]string{{"series", "lastObservedTime"}, {"lastTimestamp"}, {"eventTime"}} {
+		if ts, _, _ := unstructured.NestedString(u.Object, f...); ts != "" {
+			if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				return t
+			}
+		}
+	}
+	return u.GetCreationTimestamp().Time
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+var resourceQuotasGVR = schema.GroupVersionResource{Version: "v1", Resource: "resourcequotas"}
+
+// printQuota prints, for every ResourceQuota in ns, how much of each tracked resource
+// is consumed by objects in the tree next to the namespace-wide usage and the hard limit.
+func printQuota(client dynamic.Interface, ns string, apis *resourceMap, objs objectDirectory, uids map[types.UID]bool) error {
+	quotas, err := client.Resource(resourceQuotasGVR).Namespace(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list resourcequotas: %w", err)
+	}
+
+	var tree []unstructured.Unstructured
+	for uid := range uids {
+		if o, ok := objs.items[uid]; ok {
+			tree = append(tree, o)
+		}
+	}
+
+	if len(quotas.Items) == 0 {
+		fmt.Printf("No ResourceQuotas in namespace %q.\n", ns)
+		return printLimitRanges(client, ns, tree)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i, q := range quotas.Items {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		hard, _, _ := unstructured.NestedStringMap(q.Object, "status", "hard")
+		used, _, _ := unstructured.NestedStringMap(q.Object, "status", "used")
+		keys := make([]string, 0, len(hard))
+		for k := range hard {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintf(w, "ResourceQuota %s\n", q.GetName())
+		fmt.Fprintln(w, "RESOURCE\tTREE\tUSED\tHARD")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k, treeUsage(k, tree, apis), used[k], hard[k])
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	return printLimitRanges(client, ns, tree)
+}
+
+var limitRangesGVR = schema.GroupVersionResource{Version: "v1", Resource: "limitranges"}
+
+// printLimitRanges prints the constraints and defaults of every LimitRange in ns next to
+// the largest request and limit of each constrained resource among the tree's
+// containers, pods or claims, whichever the constraint applies to.
+func printLimitRanges(client dynamic.Interface, ns string, tree []unstructured.Unstructured) error {
+	ranges, err := client.Resource(limitRangesGVR).Namespace(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list limitranges: %w", err)
+	}
+	if len(ranges.Items) == 0 {
+		fmt.Printf("No LimitRanges in namespace %q.\n", ns)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i, lr := range ranges.Items {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "LimitRange %s\n", lr.GetName())
+		fmt.Fprintln(w, "TYPE\tRESOURCE\tMIN\tMAX\tDEFAULT-REQUEST\tDEFAULT-LIMIT\tMAX-RATIO\tTREE-REQUEST\tTREE-LIMIT")
+		limits, _, _ := unstructured.NestedSlice(lr.Object, "spec", "limits")
+		for _, l := range limits {
+			item, ok := l.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			typ, _, _ := unstructured.NestedString(item, "type")
+			fields := map[string]map[string]string{}
+			names := map[string]bool{}
+			for _, f := range []string{"min", "max", "defaultRequest", "default", "maxLimitRequestRatio"} {
+				fields[f], _, _ = unstructured.NestedStringMap(item, f)
+				for name := range fields[f] {
+					names[name] = true
+				}
+			}
+			var sorted []string
+			for name := range names {
+				sorted = append(sorted, name)
+			}
+			sort.Strings(sorted)
+			for _, name := range sorted {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", typ, name,
+					orDash(fields["min"][name]), orDash(fields["max"][name]),
+					orDash(fields["defaultRequest"][name]), orDash(fields["default"][name]),
+					orDash(fields["maxLimitRequestRatio"][name]),
+					largestUsage(tree, typ, "requests", name), largestUsage(tree, typ, "limits", name))
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// largestUsage returns the largest request or limit (field) of resource name among the
+// tree's objects of the LimitRange type typ: single containers, whole pods, or
+// PersistentVolumeClaims. It returns "-" if none sets it.
+func largestUsage(tree []unstructured.Unstructured, typ, field, name string) string {
+	var values [][]string
+	switch typ {
+	case "Container", "Pod":
+		for _, p := range activePods(tree) {
+			if typ == "Container" {
+				cs := podContainerResources(p, field, name, true)
+				for _, c := range cs {
+					values = append(values, []string{c})
+				}
+			} else {
+				// init containers run before the others and are not part of the sum
+				values = append(values, podContainerResources(p, field, name, false))
+			}
+		}
+	case "PersistentVolumeClaim":
+		for _, o := range tree {
+			if o.GetKind() == "PersistentVolumeClaim" {
+				v, _, _ := unstructured.NestedString(o.Object, "spec", "resources", field, name)
+				values = append(values, []string{v})
+			}
+		}
+	}
+
+	var largest *resource.Quantity
+	for _, vs := range values {
+		var sum resource.Quantity
+		set := false
+		for _, v := range vs {
+			if q, err := resource.ParseQuantity(v); err == nil {
+				sum.Add(q)
+				set = true
+			}
+		}
+		if set && (largest == nil || sum.Cmp(*largest) > 0) {
+			s := sum
+			largest = &s
+		}
+	}
+	if largest == nil {
+		return "-"
+	}
+	return largest.String()
+}
+
+// podContainerResources returns the request or limit (field) of resource name of each
+// container of pod, and init container if withInit is set, empty for containers that do
+// not set it.
+func podContainerResources(pod unstructured.Unstructured, field, name string, withInit bool) []string {
+	lists := []string{"containers"}
+	if withInit {
+		lists = append(lists, "initContainers")
+	}
+	var out []string
+	for _, list := range lists {
+		containers, _, _ := unstructured.NestedSlice(pod.Object, "spec", list)
+		for _, c := range containers {
+			cm, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			v, _, _ := unstructured.NestedString(cm, "resources", field, name)
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// treeUsage computes how much of the quota resource name is consumed by objs,
+// or "-" when the resource is not attributable to individual objects.
+func treeUsage(name string, objs []unstructured.Unstructured, apis *resourceMap) string {
+	switch {
+	case name == "pods" || name == "count/pods":
+		return fmt.Sprint(len(activePods(objs)))
+	case name == "requests.storage":
+		return sumQuantities(objs, "PersistentVolumeClaim", func(o unstructured.Unstructured) []string {
+			v, _, _ := unstructured.NestedString(o.Object, "spec", "resources", "requests", "storage")
+			return []string{v}
+		})
+	case strings.HasPrefix(name, "count/"):
+		return fmt.Sprint(countResource(strings.TrimPrefix(name, "count/"), objs, apis))
+	case strings.HasPrefix(name, "requests."):
+		return sumContainerResources(activePods(objs), "requests", strings.TrimPrefix(name, "requests."))
+	case strings.HasPrefix(name, "limits."):
+		return sumContainerResources(activePods(objs), "limits", strings.TrimPrefix(name, "limits."))
+	case name == "cpu" || name == "memory" || name == "ephemeral-storage":
+		return sumContainerResources(activePods(objs), "requests", name)
+	case strings.Contains(name, "."):
+		// e.g. services.loadbalancers, not tracked per object
+		return "-"
+	default:
+		// core object counts, e.g. services, configmaps, secrets
+		return fmt.Sprint(countResource(name, objs, apis))
+	}
+}
+
+// activePods returns the pods in objs that still count against quota.
+func activePods(objs []unstructured.Unstructured) []unstructured.Unstructured {
+	var out []unstructured.Unstructured
+	for _, o := range objs {
+		if o.GetKind() != "Pod" || o.GetAPIVersion() != "v1" {
+			continue
+		}
+		phase, _, _ := unstructured.NestedString(o.Object, "status", "phase")
+		if phase == "Succeeded" || phase == "Failed" {
+			continue
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+func sumContainerResources(pods []unstructured.Unstructured, field, name string) string {
+	return sumQuantities(pods, "Pod", func(o unstructured.Unstructured) []string {
+		containers, _, _ := unstructured.NestedSlice(o.Object, "spec", "containers")
+		var out []string
+		for _, c := range containers {
+			cm, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			v, _, _ := unstructured.NestedString(cm, "resources", field, name)
+			out = append(out, v)
+		}
+		return out
+	})
+}
+
+func sumQuantities(objs []unstructured.Unstructured, kind string, values func(unstructured.Unstructured) []string) string {
+	var total resource.Quantity
+	for _, o := range objs {
+		if o.GetKind() != kind {
+			continue
+		}
+		for _, v := range values(o) {
+			if v == "" {
+				continue
+			}
+			q, err := resource.ParseQuantity(v)
+			if err != nil {
+				continue
+			}
+			total.Add(q)
+		}
+	}
+	return total.String()
+}
+
+// countResource counts objects of the quota resource name ("deployments.apps" or "services").
+func countResource(name string, objs []unstructured.Unstructured, apis *resourceMap) int {
+	res, group := name, ""
+	if i := strings.Index(name, "."); i >= 0 {
+		res, group = name[:i], name[i+1:]
+	}
+	var kind string
+	for _, a := range apis.resources() {
+		if a.r.Name == res && a.gv.Group == group {
+			kind = a.r.Kind
+			break
+		}
+	}
+	if kind == "" {
+		return 0
+	}
+
+	var n int
+	for _, o := range objs {
+		if o.GetKind() == kind && o.GroupVersionKind().Group == group {
+			n++
+		}
+	}
+	return n
+}
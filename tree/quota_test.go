@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// testPod returns a pod in phase whose containers request the given cpu, and whose
+// init container requests initCPU.
+func testPod(name, phase, initCPU string, cpu ...string) unstructured.Unstructured {
+	container := func(cpu string) interface{} {
+		c := map[string]interface{}{"name": "c"}
+		if cpu != "" {
+			c["resources"] = map[string]interface{}{"requests": map[string]interface{}{"cpu": cpu}}
+		}
+		return c
+	}
+	o := testObject(name)
+	o.SetKind("Pod")
+	var containers []interface{}
+	for _, c := range cpu {
+		containers = append(containers, container(c))
+	}
+	_ = unstructured.SetNestedSlice(o.Object, containers, "spec", "containers")
+	if initCPU != "" {
+		_ = unstructured.SetNestedSlice(o.Object, []interface{}{container(initCPU)}, "spec", "initContainers")
+	}
+	_ = unstructured.SetNestedField(o.Object, phase, "status", "phase")
+	return o
+}
+
+func testPVC(name, storage string) unstructured.Unstructured {
+	o := testObject(name)
+	o.SetKind("PersistentVolumeClaim")
+	_ = unstructured.SetNestedField(o.Object, storage, "spec", "resources", "requests", "storage")
+	return o
+}
+
+func TestTreeUsage(t *testing.T) {
+	tree := []unstructured.Unstructured{
+		testPod("a", "Running", "", "100m", "200m"),
+		testPod("b", "Pending", "", "250m", ""),
+		testPod("done", "Succeeded", "", "1"),
+		testPVC("data", "1Gi"),
+		testPVC("logs", "512Mi"),
+		testObject("config"),
+	}
+	apis := &resourceMap{list: []apiResource{
+		{r: metav1.APIResource{Name: "configmaps", Kind: "ConfigMap"}, gv: schema.GroupVersion{Version: "v1"}},
+		{r: metav1.APIResource{Name: "deployments", Kind: "Deployment"}, gv: schema.GroupVersion{Group: "apps", Version: "v1"}},
+	}}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"pods", "2"},
+		{"count/pods", "2"},
+		{"requests.cpu", "550m"},
+		{"cpu", "550m"},
+		{"limits.cpu", "0"},
+		{"requests.storage", "1536Mi"},
+		{"configmaps", "1"},
+		{"count/deployments.apps", "0"},
+		{"services.loadbalancers", "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := treeUsage(tt.name, tree, apis); got != tt.want {
+				t.Errorf("treeUsage(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLargestUsage(t *testing.T) {
+	tree := []unstructured.Unstructured{
+		testPod("a", "Running", "", "100m", "200m"),
+		testPod("b", "Running", "500m", "250m"),
+		testPod("done", "Failed", "", "2"),
+		testPVC("data", "1Gi"),
+	}
+	tests := []struct {
+		typ, field, name string
+		want             string
+	}{
+		// init containers count as single containers
+		{"Container", "requests", "cpu", "500m"},
+		// but not towards the pod
+		{"Pod", "requests", "cpu", "300m"},
+		{"Pod", "limits", "cpu", "-"},
+		{"PersistentVolumeClaim", "requests", "storage", "1Gi"},
+		{"Image", "requests", "storage", "-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ+"/"+tt.field, func(t *testing.T) {
+			if got := largestUsage(tree, tt.typ, tt.field, tt.name); got != tt.want {
+				t.Errorf("largestUsage(%s, %s, %s) = %q, want %q", tt.typ, tt.field, tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name.golden, or rewrites the file with -update.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("output does not match %s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestRenderTreeGolden(t *testing.T) {
+	kinded := func(o unstructured.Unstructured, kind string) unstructured.Unstructured {
+		o.SetKind(kind)
+		return o
+	}
+	withStatus := func(o unstructured.Unstructured, generation, observed int64) unstructured.Unstructured {
+		o.SetGeneration(generation)
+		o.SetCreationTimestamp(metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+		unstructured.SetNestedField(o.Object, observed, "status", "observedGeneration")
+		return o
+	}
+	pending := func(o unstructured.Unstructured) unstructured.Unstructured {
+		o.Object["status"] = map[string]interface{}{"phase": "Pending", "conditions": []interface{}{map[string]interface{}{
+			"type": "PodScheduled", "status": "False", "reason": "Unschedulable",
+			"message": "0/12 nodes are available: 4 node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }, 8 Insufficient cpu. preemption: 0/12 nodes are available: 12 No preemption victims found for incoming pod.",
+		}}}
+		return o
+	}
+	var deep []unstructured.Unstructured
+	deep = append(deep, kinded(testObject("level-0"), "Application"))
+	for i := 1; i < 12; i++ {
+		deep = append(deep, testObject(fmt.Sprintf("level-%d", i), fmt.Sprintf("level-%d", i-1)))
+	}
+	var wide []unstructured.Unstructured
+	wide = append(wide, kinded(testObject("web"), "Deployment"), kinded(testObject("web-rs", "web"), "ReplicaSet"))
+	for i := 0; i < 25; i++ {
+		wide = append(wide, kinded(testObject(fmt.Sprintf("web-rs-%02d", i), "web-rs"), "Pod"))
+	}
+
+	tests := []struct {
+		name string
+		objs []unstructured.Unstructured
+	}{
+		{"deep", deep},
+		{"wide", wide},
+		{"multi-owner", []unstructured.Unstructured{
+			kinded(testObject("app"), "Application"),
+			kinded(testObject("db", "app"), "StatefulSet"),
+			kinded(testObject("web", "app"), "Deployment"),
+			// shown once, under whichever of its owners is printed first
+			kinded(testObject("shared-config", "web", "db"), "ConfigMap"),
+			kinded(testObject("shared-config-child", "shared-config"), "Secret"),
+			// owned by an object outside of the tree too
+			kinded(testObject("outside"), "Namespace"),
+			kinded(testObject("half-owned", "outside", "web"), "Service"),
+		}},
+		{"notes", []unstructured.Unstructured{
+			kinded(testObject("operator-app"), "Application"),
+			withStatus(kinded(testObject("stuck", "operator-app"), "Deployment"), 5, 3),
+			withStatus(kinded(testObject("current", "operator-app"), "Deployment"), 4, 4),
+			pending(kinded(testObject("pending-pod", "current"), "Pod")),
+		}},
+		{"unicode", []unstructured.Unstructured{
+			kinded(testObject("café"), "Deployment"),
+			kinded(testObject("café-ünïcode", "café"), "ReplicaSet"),
+			kinded(testObject("日本語-pod", "café-ünïcode"), "Pod"),
+			kinded(testObject("emoji-🚀", "café-ünïcode"), "Pod"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkGolden(t, "tree-"+tt.name, renderTree(tt.objs[0], newObjectDirectory(tt.objs), nil, false))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// rootDiffContainer is the container name of the lines reporting changes of the root.
+const rootDiffContainer = "changes"
+
+// watchRoot sends a line to s for every change of the root's spec and key status fields
+// while following, so that changes made mid-incident show up in the log timeline. It
+// returns when the watch fails.
+func watchRoot(tree *treeIndex, root unstructured.Unstructured, s *logStreamer) error {
+	api, err := tree.apis.forKind(root.GetAPIVersion(), root.GetKind())
+	if err != nil {
+		return err
+	}
+	// lines are attributed to a pod named after the root
+	source := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: root.GetNamespace(), Name: displayName(root)}}
+	last := watchedFields(root)
+	opts := metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", root.GetName()).String(),
+		ResourceVersion: root.GetResourceVersion(),
+	}
+	for {
+		w, err := resourceInterface(tree.client, api, tree.ns).Watch(context.TODO(), opts)
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", displayName(root), err)
+		}
+		for ev := range w.ResultChan() {
+			if ev.Type == watch.Error {
+				// most likely an expired resourceVersion, start over; the current
+				// state is compared with the last one seen
+				opts.ResourceVersion = ""
+				break
+			}
+			u, ok := ev.Object.(*unstructured.Unstructured)
+			if !ok || u.GetUID() != root.GetUID() {
+				continue
+			}
+			opts.ResourceVersion = u.GetResourceVersion()
+			var changes []string
+			if ev.Type == watch.Deleted {
+				changes = []string{"deleted"}
+			} else {
+				cur := watchedFields(*u)
+				changes = diffFields(last, cur)
+				last = cur
+			}
+			for _, c := range changes {
+				l := logLine{pod: source, container: rootDiffContainer, text: c, timestamp: time.Now()}
+				s.emit(context.Background(), l)
+			}
+		}
+		// the server closes watches periodically, resume from the last seen version
+		w.Stop()
+	}
+}
+
+// watchedFields returns the spec of o and its key status fields, the phase and the
+// status of each condition, flattened by path.
+func watchedFields(o unstructured.Unstructured) map[string]string {
+	out := make(map[string]string)
+	if spec, ok := o.Object["spec"]; ok {
+		flattenValue("spec", spec, out)
+	}
+	if phase, ok, _ := unstructured.NestedString(o.Object, "status", "phase"); ok {
+		out["status.phase"] = phase
+	}
+	conditions, _, _ := unstructured.NestedSlice(o.Object, "status", "conditions")
+	for _, c := range conditions {
+		cm, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		typ, _, _ := unstructured.NestedString(cm, "type")
+		status, _, _ := unstructured.NestedString(cm, "status")
+		if typ != "" {
+			out["status.conditions["+typ+"]"] = status
+		}
+	}
+	return out
+}
+
+// flattenValue adds the leaves of v to out under their dotted path, with list items
+// indexed as path[i].
+func flattenValue(path string, v interface{}, out map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			out[path] = "{}"
+		}
+		for k, e := range v {
+			flattenValue(path+"."+k, e, out)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			out[path] = "[]"
+		}
+		for i, e := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", path, i), e, out)
+		}
+	default:
+		out[path] = fieldString(v)
+	}
+}
+
+// diffFields returns a line for every path added, removed or changed from old to cur,
+// in path order.
+func diffFields(old, cur map[string]string) []string {
+	paths := make(map[string]bool)
+	for p := range old {
+		paths[p] = true
+	}
+	for p := range cur {
+		paths[p] = true
+	}
+	var sorted []string
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var out []string
+	for _, p := range sorted {
+		o, inOld := old[p]
+		c, inCur := cur[p]
+		switch {
+		case !inOld:
+			out = append(out, fmt.Sprintf("%s: added %s", p, quoteIfSpaced(c)))
+		case !inCur:
+			out = append(out, fmt.Sprintf("%s: removed (was %s)", p, quoteIfSpaced(o)))
+		case o != c:
+			out = append(out, fmt.Sprintf("%s: %s -> %s", p, quoteIfSpaced(o), quoteIfSpaced(c)))
+		}
+	}
+	return out
+}
+
+func quoteIfSpaced(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\n") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
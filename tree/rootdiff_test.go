@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiffWatchedFields(t *testing.T) {
+	deployment := func(replicas int64, image string, env []interface{}, ready string) unstructured.Unstructured {
+		o := testObject("web")
+		_ = unstructured.SetNestedField(o.Object, replicas, "spec", "replicas")
+		containers := []interface{}{map[string]interface{}{"name": "app", "image": image}}
+		if env != nil {
+			containers[0].(map[string]interface{})["env"] = env
+		}
+		_ = unstructured.SetNestedSlice(o.Object, containers, "spec", "template", "spec", "containers")
+		_ = unstructured.SetNestedSlice(o.Object, []interface{}{
+			map[string]interface{}{"type": "Available", "status": ready, "lastTransitionTime": "now"},
+		}, "status", "conditions")
+		// not a key status field
+		_ = unstructured.SetNestedField(o.Object, replicas, "status", "replicas")
+		return o
+	}
+	env := []interface{}{map[string]interface{}{"name": "MODE", "value": "safe mode"}}
+	tests := []struct {
+		desc     string
+		old, cur unstructured.Unstructured
+		want     []string
+	}{
+		{"unchanged", deployment(3, "web:1", nil, "True"), deployment(3, "web:1", nil, "True"), nil},
+		{"changed", deployment(3, "web:1", nil, "True"), deployment(5, "web:2", nil, "False"), []string{
+			"spec.replicas: 3 -> 5",
+			"spec.template.spec.containers[0].image: web:1 -> web:2",
+			"status.conditions[Available]: True -> False",
+		}},
+		{"added", deployment(3, "web:1", nil, "True"), deployment(3, "web:1", env, "True"), []string{
+			"spec.template.spec.containers[0].env[0].name: added MODE",
+			`spec.template.spec.containers[0].env[0].value: added "safe mode"`,
+		}},
+		{"removed", deployment(3, "web:1", env, "True"), deployment(3, "web:1", nil, "True"), []string{
+			"spec.template.spec.containers[0].env[0].name: removed (was MODE)",
+			`spec.template.spec.containers[0].env[0].value: removed (was "safe mode")`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := diffFields(watchedFields(tt.old), watchedFields(tt.cur))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffFields() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
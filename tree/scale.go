@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// scaleTarget is an object in the tree that can be scaled through its scale subresource.
+type scaleTarget struct {
+	obj unstructured.Unstructured
+	api apiResource
+}
+
+// findScaleTargets returns the objects in uids that have a scale subresource and are not
+// controlled by another such object, since scaling those would be undone by their
+// controller. If kinds is not empty only objects matching one of them are returned.
+func findScaleTargets(client dynamic.Interface, apis *resourceMap, ns string, objs objectDirectory, uids map[types.UID]bool, kinds []string) []scaleTarget {
+	candidates := make(map[types.UID]scaleTarget)
+	for uid := range uids {
+		o, ok := objs.items[uid]
+		if !ok {
+			continue
+		}
+		if _, found, _ := unstructured.NestedInt64(o.Object, "spec", "replicas"); !found {
+			continue
+		}
+		api, err := apis.forKind(o.GetAPIVersion(), o.GetKind())
+		if err != nil {
+			continue
+		}
+		if _, err := resourceInterface(client, api, ns).Get(context.TODO(), o.GetName(), metav1.GetOptions{}, "scale"); err != nil {
+			continue
+		}
+		candidates[uid] = scaleTarget{obj: o, api: api}
+	}
+
+	var out []scaleTarget
+	for _, t := range candidates {
+		if ref := metav1.GetControllerOf(&t.obj); ref != nil {
+			if _, ok := candidates[ref.UID]; ok {
+				continue
+			}
+		}
+		if len(kinds) > 0 && !matchesKind(t.api, kinds) {
+			continue
+		}
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return displayName(out[i].obj) < displayName(out[j].obj) })
+	return out
+}
+
+// matchesKind reports whether one of kinds names api, by kind, plural, singular or short name.
+func matchesKind(api apiResource, kinds []string) bool {
+	names := append([]string{api.r.Kind, api.r.Name, api.r.SingularName}, api.r.ShortNames...)
+	for _, k := range kinds {
+		for _, n := range names {
+			if n != "" && strings.EqualFold(k, n) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scaleTree sets the replicas of every scale target in the tree. Nothing is changed
+// unless confirm is set; the targets are listed instead. With wait it then polls until
+// every target reports the new replica count, failing after waitTimeout.
+func scaleTree(client dynamic.Interface, targets []scaleTarget, ns string, replicas int64, wait bool, waitTimeout time.Duration, confirm bool) error {
+	if len(targets) == 0 {
+		fmt.Println("No scalable objects found in the tree.")
+		return nil
+	}
+	if !confirm {
+		for _, t := range targets {
+			fmt.Printf("would scale %s to %d replicas\n", displayName(t.obj), replicas)
+		}
+		fmt.Println("Nothing was changed, run again with --yes to proceed.")
+		return nil
+	}
+
+	patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas))
+	for _, t := range targets {
+		_, err := resourceInterface(client, t.api, ns).Patch(context.TODO(), t.obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}, "scale")
+		if err != nil {
+			return fmt.Errorf("failed to scale %s: %w", displayName(t.obj), err)
+		}
+		fmt.Printf("scaled %s to %d replicas\n", displayName(t.obj), replicas)
+	}
+	if !wait {
+		return nil
+	}
+
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		var pending []string
+		for _, t := range targets {
+			s, err := resourceInterface(client, t.api, ns).Get(context.TODO(), t.obj.GetName(), metav1.GetOptions{}, "scale")
+			if err != nil {
+				return fmt.Errorf("failed to get scale of %s: %w", displayName(t.obj), err)
+			}
+			if current, _, _ := unstructured.NestedInt64(s.Object, "status", "replicas"); current != replicas {
+				pending = append(pending, fmt.Sprintf("%s (%d)", displayName(t.obj), current))
+			}
+		}
+		if len(pending) == 0 {
+			fmt.Println("All scaled objects have converged.")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s", waitTimeout, strings.Join(pending, ", "))
+		}
+		fmt.Printf("waiting for %s\n", strings.Join(pending, ", "))
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A pushSink sends at most sinkBatchSize lines per request, and holds lines for at
+// most sinkFlushInterval before sending them.
+const (
+	sinkBatchSize     = 500
+	sinkFlushInterval = time.Second
+)
+
+// sinkSpec is a destination that log lines are pushed to.
+type sinkSpec struct {
+	kind string // loki or webhook
+	url  string
+}
+
+// sinkList is a repeatable flag of sinks, as KIND=URL.
+type sinkList []sinkSpec
+
+func (l *sinkList) String() string {
+	var s []string
+	for _, sp := range *l {
+		s = append(s, sp.kind+"="+sp.url)
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *sinkList) Set(v string) error {
+	kind, url, ok := strings.Cut(v, "=")
+	if !ok || url == "" {
+		return fmt.Errorf("%q is not in KIND=URL form", v)
+	}
+	if kind != "loki" && kind != "webhook" {
+		return fmt.Errorf("unknown sink %q, must be loki or webhook", kind)
+	}
+	*l = append(*l, sinkSpec{kind: kind, url: url})
+	return nil
+}
+
+// teeWriter writes every line to each of its lineWriters.
+type teeWriter []lineWriter
+
+func (t teeWriter) write(l logLine) error {
+	for _, w := range t {
+		if err := w.write(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushSink is a lineWriter that POSTs lines to a URL in batches. A failed request is
+// returned by the next write, which stops streaming.
+type pushSink struct {
+	url         string
+	contentType string
+	encode      func(lines []logLine) ([]byte, error)
+	client      *http.Client
+
+	mu      sync.Mutex
+	pending []logLine
+	err     error // of the last request sent in the background
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newPushSink returns a pushSink for spec and starts sending its lines. Webhooks
+// receive the NDJSON records of --output json, with the settings of lw; Loki receives
+// the message of each line, with its namespace, pod and container as labels.
+func newPushSink(spec sinkSpec, lw *logWriter) *pushSink {
+	s := &pushSink{
+		url:    spec.url,
+		client: &http.Client{Timeout: 30 * time.Second},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	switch spec.kind {
+	case "loki":
+		s.contentType = "application/json"
+		s.encode = func(lines []logLine) ([]byte, error) { return lokiPush(lines, lw.cluster) }
+	default:
+		s.contentType = "application/x-ndjson"
+		s.encode = func(lines []logLine) ([]byte, error) {
+			var b bytes.Buffer
+			for _, l := range lines {
+				if err := lw.writeJSON(&b, l); err != nil {
+					return nil, err
+				}
+			}
+			return b.Bytes(), nil
+		}
+	}
+	go s.run()
+	return s
+}
+
+func (s *pushSink) write(l logLine) error {
+	s.mu.Lock()
+	if err := s.err; err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.pending = append(s.pending, l)
+	full := len(s.pending) >= sinkBatchSize
+	s.mu.Unlock()
+	if full {
+		return s.flush()
+	}
+	return nil
+}
+
+// run sends the pending lines every sinkFlushInterval until close is called.
+func (s *pushSink) run() {
+	defer close(s.done)
+	tick := time.NewTicker(sinkFlushInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			if err := s.flush(); err != nil {
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// flush sends the pending lines.
+func (s *pushSink) flush() error {
+	s.mu.Lock()
+	lines := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	if len(lines) == 0 {
+		return nil
+	}
+	body, err := s.encode(lines)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, s.contentType, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to push logs to %s: %w", s.url, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to push logs to %s: %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+// close sends the remaining lines and stops s.
+func (s *pushSink) close() error {
+	close(s.stop)
+	<-s.done
+	if err := s.flush(); err != nil {
+		return err
+	}
+	return s.err
+}
+
+// lokiPush returns the body of a Loki push request for lines, with one stream per
+// container.
+func lokiPush(lines []logLine, cluster string) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var streams []*stream
+	byContainer := make(map[string]*stream)
+	for _, l := range lines {
+		key := l.pod.Namespace + "/" + l.pod.Name + "/" + l.container
+		st := byContainer[key]
+		if st == nil {
+			labels := map[string]string{"namespace": l.pod.Namespace, "pod": l.pod.Name, "container": l.container}
+			if cluster != "" {
+				labels["cluster"] = cluster
+			}
+			st = &stream{Stream: labels}
+			byContainer[key] = st
+			streams = append(streams, st)
+		}
+		ts := l.timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(ts.UnixNano(), 10), l.text})
+	}
+	return json.Marshal(map[string]interface{}{"streams": streams})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSinkListSet(t *testing.T) {
+	tests := []struct {
+		v       string
+		want    sinkSpec
+		wantErr bool
+	}{
+		{"loki=http://loki:3100/loki/api/v1/push", sinkSpec{"loki", "http://loki:3100/loki/api/v1/push"}, false},
+		{"webhook=https://example.com/hook?a=b", sinkSpec{"webhook", "https://example.com/hook?a=b"}, false},
+		{"kafka=broker:9092", sinkSpec{}, true},
+		{"loki=", sinkSpec{}, true},
+		{"http://loki:3100", sinkSpec{}, true},
+	}
+	for _, tt := range tests {
+		var l sinkList
+		err := l.Set(tt.v)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q) error = %v, want error %v", tt.v, err, tt.wantErr)
+			continue
+		}
+		if err == nil && l[0] != tt.want {
+			t.Errorf("Set(%q) = %+v, want %+v", tt.v, l[0], tt.want)
+		}
+	}
+}
+
+func TestPushSink(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-1"}}
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lines := []logLine{
+		{pod: pod, container: "app", text: "started", timestamp: ts},
+		{pod: pod, container: "proxy", text: "ready", timestamp: ts.Add(time.Second)},
+		{pod: pod, container: "app", text: "GET /", timestamp: ts.Add(2 * time.Second)},
+	}
+	tests := []struct {
+		kind            string
+		wantContentType string
+		want            string
+	}{
+		{"webhook", "application/x-ndjson", `{"cluster":"prod","namespace":"shop","pod":"web-1","container":"app","timestamp":"2024-01-01T12:00:00Z","message":"started"}
+{"cluster":"prod","namespace":"shop","pod":"web-1","container":"proxy","timestamp":"2024-01-01T12:00:01Z","message":"ready"}
+{"cluster":"prod","namespace":"shop","pod":"web-1","container":"app","timestamp":"2024-01-01T12:00:02Z","message":"GET /"}
+`},
+		{"loki", "application/json", `{"streams":[` +
+			`{"stream":{"cluster":"prod","container":"app","namespace":"shop","pod":"web-1"},"values":[["1704110400000000000","started"],["1704110402000000000","GET /"]]},` +
+			`{"stream":{"cluster":"prod","container":"proxy","namespace":"shop","pod":"web-1"},"values":[["1704110401000000000","ready"]]}]}`},
+	}
+	for _, tt := range tests {
+		var mu sync.Mutex
+		var bodies []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ct := r.Header.Get("Content-Type"); ct != tt.wantContentType {
+				t.Errorf("%s: Content-Type = %q, want %q", tt.kind, ct, tt.wantContentType)
+			}
+			b, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(b))
+			mu.Unlock()
+		}))
+		s := newPushSink(sinkSpec{tt.kind, srv.URL}, &logWriter{cluster: "prod"})
+		for _, l := range lines {
+			if err := s.write(l); err != nil {
+				t.Fatalf("%s: write() error = %v", tt.kind, err)
+			}
+		}
+		if err := s.close(); err != nil {
+			t.Fatalf("%s: close() error = %v", tt.kind, err)
+		}
+		srv.Close()
+		if got := strings.Join(bodies, ""); got != tt.want {
+			t.Errorf("%s: pushed\n%s\nwant\n%s", tt.kind, got, tt.want)
+		}
+		if tt.kind == "loki" && !json.Valid([]byte(bodies[0])) {
+			t.Errorf("loki: invalid JSON body %s", bodies[0])
+		}
+	}
+}
+
+func TestPushSinkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	s := newPushSink(sinkSpec{"webhook", srv.URL}, &logWriter{})
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-1"}}
+	if err := s.write(logLine{pod: pod, container: "app", text: "x"}); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if err := s.close(); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("close() error = %v, want the 503 response", err)
+	}
+}
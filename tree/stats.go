@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// lineStats counts log lines by pod and container instead of writing them.
+type lineStats struct {
+	out      io.Writer
+	interval time.Duration
+
+	mu         sync.Mutex
+	start      time.Time
+	lastReport time.Time
+	total      map[string]int64 // lines by source since start
+	recent     map[string]int64 // lines by source since lastReport
+}
+
+func newLineStats(out io.Writer, interval time.Duration) *lineStats {
+	now := time.Now()
+	return &lineStats{
+		out:        out,
+		interval:   interval,
+		start:      now,
+		lastReport: now,
+		total:      make(map[string]int64),
+		recent:     make(map[string]int64),
+	}
+}
+
+func (st *lineStats) write(l logLine) error {
+	src := l.pod.Namespace + "/" + l.pod.Name + "/" + l.container
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.total[src]++
+	st.recent[src]++
+	return nil
+}
+
+// run counts the lines written by stream, printing a summary every interval and a
+// final one when stream returns or the process is interrupted.
+func (st *lineStats) run(stream func(lineWriter) error) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	errc := make(chan error, 1)
+	go func() { errc <- stream(st) }()
+
+	t := time.NewTicker(st.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			st.report(false)
+		case <-sig:
+			st.report(true)
+			return nil
+		case err := <-errc:
+			st.report(true)
+			return err
+		}
+	}
+}
+
+// report prints the lines per second of every source since the last report, or since
+// start for the final report, busiest first.
+func (st *lineStats) report(final bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	counts, since := st.recent, st.lastReport
+	if final {
+		counts, since = st.total, st.start
+	}
+	elapsed := now.Sub(since).Seconds()
+
+	var sources []string
+	for src := range st.total {
+		sources = append(sources, src)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		if counts[sources[i]] != counts[sources[j]] {
+			return counts[sources[i]] > counts[sources[j]]
+		}
+		return sources[i] < sources[j]
+	})
+
+	if final {
+		fmt.Fprintf(st.out, "Totals over %s:\n", now.Sub(st.start).Round(time.Second))
+	} else {
+		fmt.Fprintf(st.out, "%s:\n", now.Format(time.RFC3339))
+	}
+	tw := tabwriter.NewWriter(st.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tLINES/S\tTOTAL")
+	for _, src := range sources {
+		fmt.Fprintf(tw, "%s\t%.1f\t%d\n", src, float64(counts[src])/elapsed, st.total[src])
+	}
+	tw.Flush()
+	fmt.Fprintln(st.out)
+
+	st.recent = make(map[string]int64)
+	st.lastReport = now
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// printTerminations prints the current and last termination of every container
+// of the pods in the tree, so crashes are visible even when the logs end abruptly.
+func printTerminations(objs objectDirectory, uids map[types.UID]bool) error {
+	var pods []unstructured.Unstructured
+	for uid := range uids {
+		if o, ok := objs.items[uid]; ok && o.GetKind() == "Pod" && o.GetAPIVersion() == "v1" {
+			pods = append(pods, o)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].GetName() < pods[j].GetName() })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	var rows int
+	for _, pod := range pods {
+		for _, field := range []string{"initContainerStatuses", "containerStatuses"} {
+			statuses, _, _ := unstructured.NestedSlice(pod.Object, "status", field)
+			for _, s := range statuses {
+				cs, ok := s.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _, _ := unstructured.NestedString(cs, "name")
+				restarts, _, _ := unstructured.NestedInt64(cs, "restartCount")
+				for _, state := range []string{"state", "lastState"} {
+					t, ok, _ := unstructured.NestedMap(cs, state, "terminated")
+					if !ok {
+						continue
+					}
+					if rows == 0 {
+						fmt.Fprintln(w, "POD\tCONTAINER\tRESTARTS\tSTATE\tEXIT\tREASON\tFINISHED")
+					}
+					rows++
+					exitCode, _, _ := unstructured.NestedInt64(t, "exitCode")
+					reason, _, _ := unstructured.NestedString(t, "reason")
+					finished, _, _ := unstructured.NestedString(t, "finishedAt")
+					fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\t%s\n",
+						pod.GetName(), name, restarts, state, exitCode, reason, finished)
+				}
+			}
+		}
+	}
+	if rows == 0 {
+		fmt.Println("No container terminations recorded for pods in the tree.")
+		return nil
+	}
+	return w.Flush()
+}
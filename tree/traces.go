@@ -0,0 +1,56 @@
+package main
+
+import "strings"
+
+// traceIDFields and spanIDFields are the JSON fields trace and span IDs are looked up
+// in, in order, covering the common OpenTelemetry, Zipkin and ECS spellings.
+var (
+	traceIDFields = [][]string{{"trace_id"}, {"traceId"}, {"traceID"}, {"trace.id"}, {"trace", "id"}}
+	spanIDFields  = [][]string{{"span_id"}, {"spanId"}, {"spanID"}, {"span.id"}, {"span", "id"}}
+)
+
+// traceIDs returns the trace and span IDs of a JSON line, empty if it has none.
+func traceIDs(fields map[string]interface{}) (traceID, spanID string) {
+	find := func(paths [][]string) string {
+		for _, p := range paths {
+			if v, ok := lookupField(fields, p); ok {
+				if s := fieldString(v); s != "" && s != "null" {
+					return s
+				}
+			}
+		}
+		return ""
+	}
+	return find(traceIDFields), find(spanIDFields)
+}
+
+// traceLinkData is what a --trace-link template is executed with.
+type traceLinkData struct {
+	TraceID   string
+	SpanID    string
+	Namespace string
+	PodName   string
+}
+
+// traceLink returns the --trace-link URL for l, or "" if l has no trace ID or no
+// template is set.
+func (w *logWriter) traceLink(l logLine) string {
+	if w.traceTemplate == nil || l.fields == nil {
+		return ""
+	}
+	traceID, spanID := traceIDs(l.fields)
+	if traceID == "" {
+		return ""
+	}
+	var b strings.Builder
+	err := w.traceTemplate.Execute(&b, traceLinkData{
+		TraceID:   traceID,
+		SpanID:    spanID,
+		Namespace: l.pod.Namespace,
+		PodName:   l.pod.Name,
+	})
+	if err != nil {
+		return ""
+	}
+	return b.String()
+}